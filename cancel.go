@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// exitCodeCanceled the exit status of the plugin if the build is canceled, the shell convention for processes
+	// terminated by SIGINT
+	exitCodeCanceled = 130
+
+	// cleanupTimeout the time limit of cleaning up the resources created in a canceled run
+	cleanupTimeout = time.Minute
+)
+
+// signalContext returns a context canceled as soon as the process receives SIGINT or SIGTERM, which Drone sends when
+// the build is canceled. A second signal terminates the process right away.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		select {
+		case sig := <-signals:
+			log.Warnf("received signal [%s], canceling ...", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// cleanup removes the resources created in this run, it's called when the run is canceled. The cluster takes its
+// deployments with it, so the deployments are only deleted if the cluster existed before the run.
+func (p *Plugin) cleanup() {
+	// the context of the run is already canceled
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	if p.createdCluster {
+		p.cleanupCluster(ctx)
+		return
+	}
+
+	// the run was canceled before the cluster creation was requested
+	if p.createdSecret {
+		p.cleanupKubernetesSecret(ctx)
+		return
+	}
+
+	if len(p.createdDeployments) == 0 {
+		log.Debug("no resources created in the canceled run")
+		return
+	}
+
+	// the dependent deployments are deleted first
+	for i := len(p.createdDeployments) - 1; i >= 0; i-- {
+		d := p.createdDeployments[i]
+		log.Warnf("cleaning up deployment [%s] created in the canceled run", d.Name)
+		if err := p.deleteDeployment(ctx, d); err != nil {
+			log.Errorf("could not clean up deployment [%s]: [%s]", d.Name, err.Error())
+		}
+	}
+}
+
+// cleanupCluster deletes the cluster created in the canceled run, the kubeconfig secret of an imported cluster is only
+// deleted afterwards as Pipeline needs it till the cluster is deleted
+func (p *Plugin) cleanupCluster(ctx context.Context) {
+	log.Warnf("cleaning up cluster [%s] created in the canceled run", p.Config.Cluster.Name)
+	deleted, err := p.deleteCluster(ctx)
+	if err != nil {
+		log.Errorf("could not clean up cluster [%s]: [%s]", p.Config.Cluster.Name, err.Error())
+		return
+	}
+
+	if !p.createdSecret {
+		return
+	}
+
+	if deleted {
+		err := p.waitForResource(ctx, fmt.Sprintf("cluster [%s] deletion", p.Config.Cluster.Name), cleanupTimeout,
+			p.ClusterDeleted)
+		if err != nil {
+			log.Errorf("could not clean up kubernetes secret of cluster [%s]: [%s]", p.Config.Cluster.Name, err.Error())
+			return
+		}
+	}
+
+	p.cleanupKubernetesSecret(ctx)
+}
+
+// cleanupKubernetesSecret deletes the kubeconfig secret of the imported cluster created in the canceled run
+func (p *Plugin) cleanupKubernetesSecret(ctx context.Context) {
+	log.Warnf("cleaning up kubernetes secret of cluster [%s] created in the canceled run", p.Config.Cluster.Name)
+	if err := p.deleteKubernetesSecret(ctx); err != nil {
+		log.Errorf("could not clean up kubernetes secret of cluster [%s]: [%s]", p.Config.Cluster.Name, err.Error())
+	}
+}
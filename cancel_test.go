@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlugin_Cleanup(t *testing.T) {
+	nginx := &Deployment{Name: "stable/nginx", ReleaseName: "nginx"}
+	mysql := &Deployment{Name: "stable/mysql", ReleaseName: "mysql"}
+
+	tests := []struct {
+		name               string
+		createdCluster     bool
+		createdDeployments []*Deployment
+		calls              []string
+	}{
+		{
+			name:           "cluster created in the run",
+			createdCluster: true,
+			calls:          []string{"DELETE /orgs/1/clusters/test-cluster?field=name"},
+		},
+		{
+			name:               "deployment created in the cluster created in the run",
+			createdCluster:     true,
+			createdDeployments: []*Deployment{nginx},
+			calls:              []string{"DELETE /orgs/1/clusters/test-cluster?field=name"},
+		},
+		{
+			name:               "deployments created in the run",
+			createdDeployments: []*Deployment{mysql, nginx},
+			calls: []string{
+				"DELETE /orgs/1/clusters/test-cluster/deployments/nginx?field=name",
+				"DELETE /orgs/1/clusters/test-cluster/deployments/mysql?field=name",
+			},
+		},
+		{
+			name: "nothing created in the run",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+
+			p := Plugin{
+				Config: Config{
+					OrgId:   1,
+					Cluster: &CustomCluster{CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"}},
+				},
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					assert.Nil(t, ctx.Err(), "the cleanup must not use the canceled context")
+					calls = append(calls, method+" "+url)
+					return &http.Response{
+						StatusCode: http.StatusAccepted,
+						Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+					}, nil
+				},
+				createdCluster:     test.createdCluster,
+				createdDeployments: test.createdDeployments,
+			}
+
+			p.cleanup()
+			assert.Equal(t, test.calls, calls)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+type (
+	// ChartVersion a version of a helm chart
+	ChartVersion struct {
+		Name       string `json:"name"`
+		Version    string `json:"version"`
+		AppVersion string `json:"appVersion"`
+	}
+
+	// ChartList the charts of a helm repository, every chart is listed with its versions
+	ChartList struct {
+		Name   string           `json:"name"`
+		Charts [][]ChartVersion `json:"charts"`
+	}
+)
+
+// ChartVersions lists the available versions of the chart in the helm repositories of the organization, all the
+// repositories are searched if no repository is given
+func (c *Client) ChartVersions(ctx context.Context, orgId int, repo string, chart string) ([]string, error) {
+	query := url.Values{}
+	if repo != "" {
+		query.Set("repo", repo)
+	}
+	query.Set("name", "^"+regexp.QuoteMeta(chart)+"$")
+	query.Set("version", "all")
+
+	path := fmt.Sprintf("/orgs/%d/helm/charts", orgId)
+	resp, err := c.do(ctx, "retrieve chart versions", http.MethodGet, path, query, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []ChartList
+	if err := decode(resp, "charts", &repos); err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, repoCharts := range repos {
+		for _, chartVersions := range repoCharts.Charts {
+			for _, version := range chartVersions {
+				if version.Name == chart {
+					versions = append(versions, version.Version)
+				}
+			}
+		}
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,174 @@
+// Package client implements a client for the Banzai Cloud Pipeline API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Doer sends HTTP requests, *http.Client satisfies it
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts an ordinary function to the Doer interface
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req)
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Client Pipeline API client, every method is bound to the context passed in
+type Client struct {
+	endpoint string
+	token    string
+	username string
+	password string
+	doer     Doer
+}
+
+// Option configures the client
+type Option func(client *Client)
+
+// WithToken authenticates the requests with the given bearer token
+func WithToken(token string) Option {
+	return func(client *Client) {
+		client.token = token
+	}
+}
+
+// WithBasicAuth authenticates the requests with the given username and password
+func WithBasicAuth(username string, password string) Option {
+	return func(client *Client) {
+		client.username = username
+		client.password = password
+	}
+}
+
+// WithDoer sends the requests through the given Doer (e.g. a preconfigured *http.Client) instead of the default
+// HTTP client
+func WithDoer(doer Doer) Option {
+	return func(client *Client) {
+		client.doer = doer
+	}
+}
+
+// New creates a client for the Pipeline API available at the given endpoint (e.g. https://example.org/pipeline/api/v1)
+func New(endpoint string, options ...Option) *Client {
+	client := &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		doer:     http.DefaultClient,
+	}
+
+	for _, option := range options {
+		option(client)
+	}
+
+	return client
+}
+
+// newRequest assembles the request for the API path; the body, if any, is sent as JSON
+func (c *Client) newRequest(ctx context.Context, method string, path string, query url.Values, body interface{}) (*http.Request, error) {
+	requestUrl := c.endpoint + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not encode request body")
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequest(method, requestUrl, bodyReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	return req.WithContext(ctx), nil
+}
+
+// do sends the request for the operation and returns the response if its status code is one of the expected ones,
+// otherwise the response is closed and an *Error is returned
+func (c *Client) do(ctx context.Context, operation string, method string, path string, query url.Values, body interface{},
+	expected ...int) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statusCode := range expected {
+		if resp.StatusCode == statusCode {
+			return resp, nil
+		}
+	}
+
+	defer resp.Body.Close()
+	return nil, newError(operation, resp)
+}
+
+// check sends the request and returns the response with its body already closed, this is used by the existence and
+// readiness checks that only look at the status code
+func (c *Client) check(ctx context.Context, method string, path string, query url.Values) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return resp, nil
+}
+
+// decode parses the JSON body of the response into v and closes the response
+func decode(resp *http.Response, what string, v interface{}) error {
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "could not read %s response", what)
+	}
+
+	err = json.Unmarshal(bodyBytes, v)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse %s response [ %s ]", what, string(bodyBytes))
+	}
+
+	return nil
+}
+
+// byName the query selecting clusters by name instead of identifier
+func byName() url.Values {
+	return url.Values{"field": []string{"name"}}
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components/helm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Organizations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/orgs", r.URL.Path)
+		assert.Equal(t, "name", r.URL.Query().Get("field"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.Write([]byte(`[{"id":1,"name":"org1"},{"id":2,"name":"org2"}]`))
+	}))
+	defer server.Close()
+
+	organizations, err := New(server.URL+"/api/v1/", WithToken("token")).Organizations(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []Organization{{Id: 1, Name: "org1"}, {Id: 2, Name: "org2"}}, organizations)
+}
+
+func TestClient_BasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "admin", username)
+		assert.Equal(t, "secret", password)
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithBasicAuth("admin", "secret")).Organizations(context.Background())
+	assert.Nil(t, err)
+}
+
+func TestClient_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":404,"message":"cluster not found","error":"record not found"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).GetClusterStatus(context.Background(), 1, "test-cluster")
+	assert.EqualError(t, err, "could not retrieve cluster status. status: [ 404 Not Found ], message: [ cluster not found ]")
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, &Error{
+		Operation:  "retrieve cluster status",
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Message:    "cluster not found",
+	}, err)
+}
+
+func TestClient_ClusterExists(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		exists     bool
+		err        bool
+	}{
+		{name: "cluster exists", statusCode: http.StatusOK, exists: true},
+		{name: "cluster not found", statusCode: http.StatusNotFound},
+		{name: "cluster not yet alive", statusCode: http.StatusNoContent},
+		{name: "unexpected response", statusCode: http.StatusInternalServerError, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodHead, r.Method)
+				assert.Equal(t, "/orgs/1/clusters/test-cluster", r.URL.Path)
+				w.WriteHeader(test.statusCode)
+			}))
+			defer server.Close()
+
+			exists, err := New(server.URL).ClusterExists(context.Background(), 1, "test-cluster")
+			assert.Equal(t, test.exists, exists)
+			assert.Equal(t, test.err, err != nil)
+		})
+	}
+}
+
+func TestClient_EscapedPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/orgs/1/clusters/team%2Fcluster%3Fa%23b/deployments/my%2Frelease", r.URL.EscapedPath())
+		assert.Equal(t, "name", r.URL.Query().Get("field"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	exists, err := New(server.URL).DeploymentExists(context.Background(), 1, "team/cluster?a#b", "my/release")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
+
+func TestClient_CreateDeployment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/orgs/1/clusters/test-cluster/deployments", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		request := helm.CreateUpdateDeploymentRequest{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "stable/nginx", request.Name)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"release_name":"nginx","notes":"installed"}`))
+	}))
+	defer server.Close()
+
+	deployment, err := New(server.URL).CreateDeployment(context.Background(), 1, "test-cluster",
+		&helm.CreateUpdateDeploymentRequest{Name: "stable/nginx", ReleaseName: "nginx"})
+	assert.Nil(t, err)
+	assert.Equal(t, &helm.CreateUpdateDeploymentResponse{ReleaseName: "nginx", Notes: "installed"}, deployment)
+}
+
+func TestClient_GetEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "nginx", r.URL.Query().Get("releaseName"))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).GetEndpoints(context.Background(), 1, "test-cluster", "nginx")
+	assert.True(t, IsStatus(err, http.StatusAccepted), "pending endpoints must be reported with the status code")
+}
+
+func TestClient_ChartVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/orgs/1/helm/charts", r.URL.Path)
+		assert.Equal(t, "stable", r.URL.Query().Get("repo"))
+		assert.Equal(t, "^mysql$", r.URL.Query().Get("name"))
+		assert.Equal(t, "all", r.URL.Query().Get("version"))
+		w.Write([]byte(`[{"name":"stable","charts":[[{"name":"mysql","version":"0.8.2"},{"name":"mysql","version":"0.8.1"}],` +
+			`[{"name":"mysqldump","version":"1.0.0"}]]}]`))
+	}))
+	defer server.Close()
+
+	versions, err := New(server.URL).ChartVersions(context.Background(), 1, "stable", "mysql")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0.8.2", "0.8.1"}, versions)
+}
+
+func TestClient_Context(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request is expected to be sent with a canceled context")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := New(server.URL).Organizations(ctx)
+	assert.NotNil(t, err)
+}
+
+func TestClient_Secrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			assert.Equal(t, "/orgs/1/secrets", r.URL.Path)
+			assert.Equal(t, "kubernetes", r.URL.Query().Get("type"))
+			w.Write([]byte(`[{"name":"test-cluster-kubeconfig","type":"kubernetes","id":"secret-id"}]`))
+		case http.MethodDelete:
+			assert.Equal(t, "/orgs/1/secrets/secret-id", r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	secrets, err := c.Secrets(context.Background(), 1, "kubernetes")
+	assert.Nil(t, err)
+	assert.Equal(t, []Secret{{Name: "test-cluster-kubeconfig", Type: "kubernetes", Id: "secret-id"}}, secrets)
+
+	assert.Nil(t, c.DeleteSecret(context.Background(), 1, "secret-id"))
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/banzaicloud/banzai-types/components"
+)
+
+// ClusterStatusResponse the cluster status as returned by the Pipeline API, including the reason of the status
+type ClusterStatusResponse struct {
+	components.GetClusterStatusResponse
+	StatusMessage string `json:"statusMessage,omitempty"`
+}
+
+// ClusterExists checks whether the cluster exists and is available
+func (c *Client) ClusterExists(ctx context.Context, orgId int, clusterName string) (bool, error) {
+	resp, err := c.check(ctx, http.MethodHead, clusterPath(orgId, clusterName), byName())
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusNoContent, http.StatusBadRequest:
+		// the cluster is either not found or not yet alive
+		return false, nil
+	}
+
+	return false, newError("check cluster existence", resp)
+}
+
+// GetClusterStatus retrieves the status of the cluster
+func (c *Client) GetClusterStatus(ctx context.Context, orgId int, clusterName string) (*ClusterStatusResponse, error) {
+	resp, err := c.do(ctx, "retrieve cluster status", http.MethodGet, clusterPath(orgId, clusterName), byName(), nil,
+		http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ClusterStatusResponse{}
+	if err := decode(resp, "cluster status", status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// CreateCluster triggers the creation of the cluster
+func (c *Client) CreateCluster(ctx context.Context, orgId int, request *components.CreateClusterRequest) (*components.CreateClusterResponse, error) {
+	path := fmt.Sprintf("/orgs/%d/clusters", orgId)
+	resp, err := c.do(ctx, "create cluster", http.MethodPost, path, nil, request, http.StatusOK, http.StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &components.CreateClusterResponse{}
+	if err := decode(resp, "create cluster", cluster); err != nil {
+		return nil, err
+	}
+
+	return cluster, nil
+}
+
+// UpdateCluster triggers the update of the cluster
+func (c *Client) UpdateCluster(ctx context.Context, orgId int, clusterName string, request *components.UpdateClusterRequest) error {
+	resp, err := c.do(ctx, "update cluster", http.MethodPut, clusterPath(orgId, clusterName), byName(), request,
+		http.StatusOK, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// DeleteCluster triggers the deletion of the cluster
+func (c *Client) DeleteCluster(ctx context.Context, orgId int, clusterName string) (*components.DeleteClusterResponse, error) {
+	resp, err := c.do(ctx, "delete cluster", http.MethodDelete, clusterPath(orgId, clusterName), byName(), nil,
+		http.StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteResponse := &components.DeleteClusterResponse{}
+	if err := decode(resp, "delete cluster", deleteResponse); err != nil {
+		return nil, err
+	}
+
+	return deleteResponse, nil
+}
+
+// GetClusterConfig retrieves the kubeconfig of the cluster
+func (c *Client) GetClusterConfig(ctx context.Context, orgId int, clusterName string) (*components.GetClusterConfigResponse, error) {
+	path := clusterPath(orgId, clusterName) + "/config"
+	resp, err := c.do(ctx, "retrieve cluster configuration", http.MethodGet, path, byName(), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &components.GetClusterConfigResponse{}
+	if err := decode(resp, "cluster configuration", config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func clusterPath(orgId int, clusterName string) string {
+	return fmt.Sprintf("/orgs/%d/clusters/%s", orgId, url.PathEscape(clusterName))
+}
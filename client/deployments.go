@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/banzaicloud/banzai-types/components/helm"
+)
+
+// HelmReady checks whether helm is set up on the cluster, so that deployments can be installed
+func (c *Client) HelmReady(ctx context.Context, orgId int, clusterName string) (bool, error) {
+	resp, err := c.check(ctx, http.MethodHead, deploymentsPath(orgId, clusterName), byName())
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusServiceUnavailable, http.StatusBadRequest:
+		// todo fix the api to return the proper statuscode!
+		return false, nil
+	}
+
+	return false, newError("check helm", resp)
+}
+
+// ListDeployments lists the deployments of the cluster
+func (c *Client) ListDeployments(ctx context.Context, orgId int, clusterName string) ([]helm.ListDeploymentResponse, error) {
+	resp, err := c.do(ctx, "list deployments", http.MethodGet, deploymentsPath(orgId, clusterName), byName(), nil,
+		http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var deployments []helm.ListDeploymentResponse
+	if err := decode(resp, "list deployments", &deployments); err != nil {
+		return nil, err
+	}
+
+	return deployments, nil
+}
+
+// DeploymentExists checks whether the release is deployed to the cluster
+func (c *Client) DeploymentExists(ctx context.Context, orgId int, clusterName string, releaseName string) (bool, error) {
+	resp, err := c.check(ctx, http.MethodHead, deploymentPath(orgId, clusterName, releaseName), byName())
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound, http.StatusNoContent:
+		// the deployment is either not found or not yet ready
+		return false, nil
+	}
+
+	return false, newError("check deployment", resp)
+}
+
+// CreateDeployment installs the deployment to the cluster
+func (c *Client) CreateDeployment(ctx context.Context, orgId int, clusterName string,
+	request *helm.CreateUpdateDeploymentRequest) (*helm.CreateUpdateDeploymentResponse, error) {
+	resp, err := c.do(ctx, "install deployment", http.MethodPost, deploymentsPath(orgId, clusterName), byName(), request,
+		http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := &helm.CreateUpdateDeploymentResponse{}
+	if err := decode(resp, "install deployment", deployment); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// UpdateDeployment upgrades the release of the deployment
+func (c *Client) UpdateDeployment(ctx context.Context, orgId int, clusterName string,
+	request *helm.CreateUpdateDeploymentRequest) (*helm.CreateUpdateDeploymentResponse, error) {
+	resp, err := c.do(ctx, "update deployment", http.MethodPut, deploymentPath(orgId, clusterName, request.ReleaseName),
+		byName(), request, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := &helm.CreateUpdateDeploymentResponse{}
+	if err := decode(resp, "update deployment", deployment); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// DeleteDeployment deletes the release from the cluster
+func (c *Client) DeleteDeployment(ctx context.Context, orgId int, clusterName string, releaseName string) (*helm.DeleteResponse, error) {
+	resp, err := c.do(ctx, "delete deployment", http.MethodDelete, deploymentPath(orgId, clusterName, releaseName),
+		byName(), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteResponse := &helm.DeleteResponse{}
+	if err := decode(resp, "delete deployment", deleteResponse); err != nil {
+		return nil, err
+	}
+
+	return deleteResponse, nil
+}
+
+// GetEndpoints retrieves the public endpoints of the release; an *Error with http.StatusAccepted is returned while the
+// load balancers of the release are not ready
+func (c *Client) GetEndpoints(ctx context.Context, orgId int, clusterName string, releaseName string) (*helm.EndpointResponse, error) {
+	query := byName()
+	query.Set("releaseName", releaseName)
+
+	path := clusterPath(orgId, clusterName) + "/endpoints"
+	resp, err := c.do(ctx, "retrieve endpoints", http.MethodGet, path, query, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := &helm.EndpointResponse{}
+	if err := decode(resp, "endpoints", endpoints); err != nil {
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+func deploymentsPath(orgId int, clusterName string) string {
+	return clusterPath(orgId, clusterName) + "/deployments"
+}
+
+func deploymentPath(orgId int, clusterName string, releaseName string) string {
+	return deploymentsPath(orgId, clusterName) + "/" + url.PathEscape(releaseName)
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/pkg/errors"
+)
+
+// Error the Pipeline API responded with a status code that is not expected for the operation
+type Error struct {
+	// Operation the operation the request was sent for (e.g. "delete cluster")
+	Operation string
+	// StatusCode the status code of the response
+	StatusCode int
+	// Status the status line of the response
+	Status string
+	// Message the error message reported by Pipeline, if any
+	Message string
+	// RetryAfter the delay requested by Pipeline before the request is retried, if any
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("could not %s. status: [ %s ], message: [ %s ]", e.Operation, e.Status, e.Message)
+	}
+	return fmt.Sprintf("could not %s. status: [ %s ]", e.Operation, e.Status)
+}
+
+// newError assembles the error reported for the unexpected response of the operation; the error message is taken
+// from the response body if it's a Pipeline error response
+func newError(operation string, resp *http.Response) *Error {
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	apiErr := &Error{
+		Operation:  operation,
+		StatusCode: resp.StatusCode,
+		Status:     status,
+		RetryAfter: RetryAfter(resp),
+	}
+
+	if bodyBytes, err := ioutil.ReadAll(resp.Body); err == nil {
+		errorResponse := components.ErrorResponse{}
+		if json.Unmarshal(bodyBytes, &errorResponse) == nil {
+			apiErr.Message = errorResponse.Message
+			if apiErr.Message == "" {
+				apiErr.Message = errorResponse.Error
+			}
+		}
+	}
+
+	return apiErr
+}
+
+// RetryAfter parses the Retry-After header of the response, both delay seconds and HTTP dates are accepted; zero is
+// returned if the header is missing or invalid
+func RetryAfter(resp *http.Response) time.Duration {
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+// IsStatus checks whether the error was caused by a response with the given status code
+func IsStatus(err error, statusCode int) bool {
+	apiErr, ok := errors.Cause(err).(*Error)
+	return ok && apiErr.StatusCode == statusCode
+}
+
+// IsNotFound checks whether the error was caused by a resource not found response
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
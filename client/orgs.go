@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/banzaicloud/banzai-types/components"
+)
+
+type (
+	// Organization a Pipeline organization
+	Organization struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	// CreateSecretRequest the secret to be stored in Pipeline
+	CreateSecretRequest struct {
+		Name   string            `json:"name"`
+		Type   string            `json:"type"`
+		Values map[string]string `json:"values"`
+	}
+
+	// CreateSecretResponse the secret stored in Pipeline
+	CreateSecretResponse struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+		Id   string `json:"id"`
+	}
+
+	// Secret a secret stored in Pipeline, without its values
+	Secret struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+		Id   string `json:"id"`
+	}
+)
+
+// Organizations lists the organizations the user is a member of
+func (c *Client) Organizations(ctx context.Context) ([]Organization, error) {
+	resp, err := c.do(ctx, "retrieve organizations", http.MethodGet, "/orgs", byName(), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var organizations []Organization
+	if err := decode(resp, "orgs", &organizations); err != nil {
+		return nil, err
+	}
+
+	return organizations, nil
+}
+
+// ClusterProfiles lists the cluster profiles of the organization for the given cloud
+func (c *Client) ClusterProfiles(ctx context.Context, orgId int, cloud string) ([]components.ClusterProfileResponse, error) {
+	path := fmt.Sprintf("/orgs/%d/profiles/cluster/%s", orgId, url.PathEscape(cloud))
+	resp, err := c.do(ctx, "retrieve cluster profiles", http.MethodGet, path, nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []components.ClusterProfileResponse
+	if err := decode(resp, "cluster profiles", &profiles); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// CreateSecret stores the secret in the organization
+func (c *Client) CreateSecret(ctx context.Context, orgId int, request *CreateSecretRequest) (*CreateSecretResponse, error) {
+	path := fmt.Sprintf("/orgs/%d/secrets", orgId)
+	resp, err := c.do(ctx, "create secret", http.MethodPost, path, nil, request, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &CreateSecretResponse{}
+	if err := decode(resp, "create secret", secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// Secrets lists the secrets of the organization with the given type
+func (c *Client) Secrets(ctx context.Context, orgId int, secretType string) ([]Secret, error) {
+	query := url.Values{}
+	query.Set("type", secretType)
+
+	path := fmt.Sprintf("/orgs/%d/secrets", orgId)
+	resp, err := c.do(ctx, "retrieve secrets", http.MethodGet, path, query, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []Secret
+	if err := decode(resp, "secrets", &secrets); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// DeleteSecret deletes the secret from the organization
+func (c *Client) DeleteSecret(ctx context.Context, orgId int, secretId string) error {
+	path := fmt.Sprintf("/orgs/%d/secrets/%s", orgId, url.PathEscape(secretId))
+	resp, err := c.do(ctx, "delete secret", http.MethodDelete, path, nil, nil, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// GetCloudInfo retrieves the cloud info of the cloud for the organization of the request
+func (c *Client) GetCloudInfo(ctx context.Context, cloud string, request *components.CloudInfoRequest) (*components.GetCloudInfoResponse, error) {
+	query := url.Values{}
+	if request.SecretId != "" {
+		query.Set("secret_id", request.SecretId)
+	}
+	if filter := request.Filter; filter != nil {
+		for _, field := range filter.Fields {
+			query.Add("fields", field)
+		}
+		if filter.InstanceType != nil && filter.InstanceType.Location != "" {
+			query.Set("location", filter.InstanceType.Location)
+		}
+		if filter.ImageFilter != nil {
+			for _, tag := range filter.ImageFilter.Tags {
+				query.Add("tags", *tag)
+			}
+		}
+	}
+
+	path := fmt.Sprintf("/orgs/%d/cloudinfo/%s", request.OrganizationId, url.PathEscape(cloud))
+	resp, err := c.do(ctx, "retrieve cloud info", http.MethodGet, path, query, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudInfo := &components.GetCloudInfoResponse{}
+	if err := decode(resp, "cloud info", cloudInfo); err != nil {
+		return nil, err
+	}
+
+	return cloudInfo, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	. "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// cloudInfoSpec the cloud specific values of the cluster that are checked against the Pipeline cloud info
+type cloudInfoSpec struct {
+	InstanceTypes      []string
+	KubernetesVersions []string
+	Images             []string
+}
+
+// fields returns the cloud info fields needed to check the spec
+func (spec *cloudInfoSpec) fields() []string {
+	fields := []string{constants.KeyWordLocation}
+	if len(spec.InstanceTypes) > 0 {
+		fields = append(fields, constants.KeyWordInstanceType)
+	}
+	if len(spec.KubernetesVersions) > 0 {
+		fields = append(fields, constants.KeyWordKubernetesVersion)
+	}
+	if len(spec.Images) > 0 {
+		fields = append(fields, constants.KeyWordImage)
+	}
+	return fields
+}
+
+// newCloudInfoRequest assembles the cloud info request for the given fields filtered by the location of the cluster
+func (p *Plugin) newCloudInfoRequest(fields ...string) *CloudInfoRequest {
+	cloudInfoRequest := &CloudInfoRequest{
+		OrganizationId: uint(p.Config.OrgId),
+		SecretId:       p.Config.Cluster.SecretId,
+	}
+
+	location := p.Config.Cluster.Location
+	cloudInfoRequest.Filter = &struct {
+		Fields           []string          `json:"fields,omitempty"`
+		InstanceType     *InstanceFilter   `json:"instanceType,omitempty"`
+		KubernetesFilter *KubernetesFilter `json:"k8sVersion,omitempty"`
+		ImageFilter      *ImageFilter      `json:"image,omitempty"`
+	}{
+		Fields:           fields,
+		InstanceType:     &InstanceFilter{Location: location},
+		KubernetesFilter: &KubernetesFilter{Location: location},
+		ImageFilter:      &ImageFilter{Location: location},
+	}
+
+	return cloudInfoRequest
+}
+
+// getCloudInfo retrieves the cloud info of the cloud of the cluster from the Pipeline API
+func (p *Plugin) getCloudInfo(ctx context.Context, cloudInfoRequest *CloudInfoRequest) (*GetCloudInfoResponse, error) {
+	return p.pipelineClient().GetCloudInfo(ctx, p.Config.Cluster.Cloud, cloudInfoRequest)
+}
+
+// validateClusterSpec checks the location, cluster name, instance types, kubernetes versions and images of the
+// cluster against the cloud info provided by Pipeline. The check is skipped if the cloud info is not available.
+func (p *Plugin) validateClusterSpec(ctx context.Context) error {
+	provider, err := getClusterProvider(p.Config.Cluster.Cloud)
+	if err != nil {
+		return err
+	}
+
+	spec := provider.cloudInfoSpec(p.Config.Cluster.CreateClusterRequest)
+	if spec == nil {
+		log.Debugf("no cloud info validation for cloud: [%s]", p.Config.Cluster.Cloud)
+		return nil
+	}
+
+	cloudInfo, err := p.getCloudInfo(ctx, p.newCloudInfoRequest(spec.fields()...))
+	if err != nil {
+		log.Warnf("skipping cluster validation, cloud info not available: [%s]", err.Error())
+		return nil
+	}
+
+	return checkCloudInfo(p.Config.Cluster.CreateClusterRequest, spec, cloudInfo)
+}
+
+// checkCloudInfo checks the cluster against the cloud info, all the problems found are reported together with the
+// valid alternatives
+func checkCloudInfo(createRequest *CreateClusterRequest, spec *cloudInfoSpec, cloudInfo *GetCloudInfoResponse) error {
+	var problems []string
+
+	if cloudInfo.NameRegexp != "" {
+		if match, err := regexp.MatchString(cloudInfo.NameRegexp, createRequest.Name); err == nil && !match {
+			problems = append(problems, fmt.Sprintf("invalid cluster name [%s], the name must match [%s]",
+				createRequest.Name, cloudInfo.NameRegexp))
+		}
+	}
+
+	location := createRequest.Location
+	if len(cloudInfo.Locations) > 0 && !contains(cloudInfo.Locations, location) {
+		problems = append(problems, fmt.Sprintf("invalid location [%s], valid locations: %s", location,
+			listOf(cloudInfo.Locations)))
+
+		// the rest of the cloud info is location specific
+		return cloudInfoError(problems)
+	}
+
+	if instanceTypes, ok := cloudInfo.NodeInstanceType[location]; ok {
+		for _, instanceType := range spec.InstanceTypes {
+			if !contains(instanceTypes, instanceType) {
+				problems = append(problems, fmt.Sprintf("invalid instance type [%s] in location [%s], valid instance types: %s",
+					instanceType, location, listOf(instanceTypes)))
+			}
+		}
+	}
+
+	if kubernetesVersions := kubernetesVersionsOf(cloudInfo); len(kubernetesVersions) > 0 {
+		for _, kubernetesVersion := range spec.KubernetesVersions {
+			if !contains(kubernetesVersions, kubernetesVersion) {
+				problems = append(problems, fmt.Sprintf("invalid kubernetes version [%s] in location [%s], valid versions: %s",
+					kubernetesVersion, location, listOf(kubernetesVersions)))
+			}
+		}
+	}
+
+	if images, ok := cloudInfo.Image[location]; ok {
+		for _, image := range spec.Images {
+			if !contains(images, image) {
+				problems = append(problems, fmt.Sprintf("invalid image [%s] in location [%s], valid images: %s",
+					image, location, listOf(images)))
+			}
+		}
+	}
+
+	return cloudInfoError(problems)
+}
+
+// kubernetesVersionsOf collects the kubernetes versions from the cloud info; depending on the cloud these are either
+// provided as a list or as lists grouped by purpose (e.g. master and node versions)
+func kubernetesVersionsOf(cloudInfo *GetCloudInfoResponse) []string {
+	var versions []string
+
+	collect := func(list []interface{}) {
+		for _, item := range list {
+			if version, ok := item.(string); ok && !contains(versions, version) {
+				versions = append(versions, version)
+			}
+		}
+	}
+
+	switch kubernetesVersions := cloudInfo.KubernetesVersions.(type) {
+	case []interface{}:
+		collect(kubernetesVersions)
+	case map[string]interface{}:
+		for _, value := range kubernetesVersions {
+			if list, ok := value.([]interface{}); ok {
+				collect(list)
+			}
+		}
+	}
+
+	return versions
+}
+
+func cloudInfoError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// appendUnique appends the non empty items that are not yet in the list
+func appendUnique(items []string, newItems ...string) []string {
+	for _, item := range newItems {
+		if item != "" && !contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// listOf formats the given items as a sorted list
+func listOf(items []string) string {
+	sorted := append([]string(nil), items...)
+	sort.Strings(sorted)
+	return "[" + strings.Join(sorted, ", ") + "]"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+const googleCloudInfoResponse = `{
+  "type": "google",
+  "nameRegexp": "^[a-z]$|^[a-z][a-z0-9-]{0,38}[a-z0-9]$",
+  "locations": ["us-central1-a", "europe-west1-b"],
+  "nodeInstanceType": {"us-central1-a": ["n1-standard-1", "n1-standard-4"]},
+  "kubernetes_versions": {"defaultClusterVersion": "1.9.7-gke.3", "validMasterVersions": ["1.10.2-gke.3", "1.9.7-gke.3"], "validNodeVersions": ["1.10.2-gke.3", "1.9.7-gke.3", "1.8.12-gke.1"]}
+}`
+
+func TestCheckCloudInfo(t *testing.T) {
+	cloudInfo := &components.GetCloudInfoResponse{}
+	assert.Nil(t, json.Unmarshal([]byte(googleCloudInfoResponse), cloudInfo))
+
+	tests := []struct {
+		name          string
+		clusterName   string
+		location      string
+		instanceTypes []string
+		versions      []string
+		err           string
+	}{
+		{
+			name:          "valid cluster",
+			clusterName:   "test-cluster",
+			location:      "us-central1-a",
+			instanceTypes: []string{"n1-standard-4"},
+			versions:      []string{"1.9.7-gke.3"},
+		},
+		{
+			name:        "invalid location",
+			clusterName: "test-cluster",
+			location:    "us-central1",
+			err:         "invalid location [us-central1], valid locations: [europe-west1-b, us-central1-a]",
+		},
+		{
+			name:          "invalid name, instance type and version",
+			clusterName:   "Test_Cluster",
+			location:      "us-central1-a",
+			instanceTypes: []string{"n1-standard-3"},
+			versions:      []string{"1.9.4-gke.1"},
+			err: "invalid cluster name [Test_Cluster], the name must match [^[a-z]$|^[a-z][a-z0-9-]{0,38}[a-z0-9]$]; " +
+				"invalid instance type [n1-standard-3] in location [us-central1-a], valid instance types: [n1-standard-1, n1-standard-4]; " +
+				"invalid kubernetes version [1.9.4-gke.1] in location [us-central1-a], valid versions: [1.10.2-gke.3, 1.8.12-gke.1, 1.9.7-gke.3]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			createRequest := &components.CreateClusterRequest{
+				Name:     test.clusterName,
+				Location: test.location,
+				Cloud:    constants.Google,
+			}
+			spec := &cloudInfoSpec{
+				InstanceTypes:      test.instanceTypes,
+				KubernetesVersions: test.versions,
+			}
+
+			err := checkCloudInfo(createRequest, spec, cloudInfo)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
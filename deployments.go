@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	installedAction = "installation"
+	upgradedAction  = "upgrade"
+	deletedAction   = "deletion"
+	checkedAction   = "check"
+	resolvedAction  = "version resolution"
+	noAction        = "nothing to do"
+)
+
+// deploymentResult the outcome of a deployment of the run
+type deploymentResult struct {
+	deployment *Deployment
+	action     string
+	started    time.Time
+	finished   time.Time
+	err        error
+	// skipped whether the deployment was skipped because a dependency failed
+	skipped bool
+}
+
+// parseDeployments parses the JSON or YAML list of deployments, the deployments without a state are installed or upgraded
+func parseDeployments(deploymentsStr string) ([]*Deployment, error) {
+	if deploymentsStr == "" {
+		return nil, nil
+	}
+
+	var deployments []*Deployment
+	err := parseDocument(deploymentsStr, &deployments)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse deployments")
+	}
+
+	for _, d := range deployments {
+		if d.State == "" {
+			d.State = createdState
+		}
+	}
+
+	return deployments, nil
+}
+
+// deployments returns the deployments of the run, the deployment of the single deployment options comes first
+func (config *Config) deployments() []*Deployment {
+	var deployments []*Deployment
+	if config.Deployment != nil && len(config.Deployment.Name) > 0 {
+		deployments = append(deployments, config.Deployment)
+	}
+	return append(deployments, config.Deployments...)
+}
+
+// chart describes the chart of the deployment with its version if set
+func (d *Deployment) chart() string {
+	if d.Version != "" {
+		return d.Name + " " + d.Version
+	}
+	return d.Name
+}
+
+// key identifies the deployment in the dependencies of the other deployments, the chart name is used if the release
+// name is not set
+func (d *Deployment) key() string {
+	if d.ReleaseName != "" {
+		return d.ReleaseName
+	}
+	return d.Name
+}
+
+// checkDeployments checks that the deployments are uniquely named and their dependencies are declared and free of cycles
+func checkDeployments(deployments []*Deployment) error {
+	declared := make(map[string]*Deployment, len(deployments))
+	for _, d := range deployments {
+		if d.Name == "" {
+			return errors.Errorf("the chart name of release [%s] is missing", d.ReleaseName)
+		}
+		if _, ok := declared[d.key()]; ok {
+			return errors.Errorf("release [%s] is declared more than once", d.key())
+		}
+		declared[d.key()] = d
+	}
+
+	for _, d := range deployments {
+		for _, dependency := range d.DependsOn {
+			if _, ok := declared[dependency]; !ok {
+				return errors.Errorf("release [%s] depends on the undeclared release [%s]", d.key(), dependency)
+			}
+		}
+	}
+
+	// the deployments are resolved in rounds, the ones left unresolved depend on each other
+	resolved := make(map[string]bool, len(deployments))
+	for progress := true; progress; {
+		progress = false
+		for _, d := range deployments {
+			if !resolved[d.key()] && allOf(d.DependsOn, resolved) {
+				resolved[d.key()] = true
+				progress = true
+			}
+		}
+	}
+
+	var cycle []string
+	for _, d := range deployments {
+		if !resolved[d.key()] {
+			cycle = append(cycle, d.key())
+		}
+	}
+	if len(cycle) > 0 {
+		return errors.Errorf("the dependencies of releases %s form a cycle", listOf(cycle))
+	}
+
+	return nil
+}
+
+// allOf checks whether all the keys are set in the given set
+func allOf(keys []string, set map[string]bool) bool {
+	for _, key := range keys {
+		if !set[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// runDeployments installs, upgrades or deletes the deployments. The requests are sent in the declared order, a deployment
+// is only sent once its dependencies are ready, while the waits of the deployments run concurrently. The outcome of the
+// deployments is summarized at the end.
+func (p *Plugin) runDeployments(ctx context.Context, deployments []*Deployment, timeouts Timeouts) error {
+	if err := checkDeployments(deployments); err != nil {
+		return errors.Wrap(err, "invalid deployments")
+	}
+
+	results := make(map[string]*deploymentResult, len(deployments))
+	// ready the deployments done successfully, failed the deployments done with an error or skipped
+	ready := make(map[string]bool, len(deployments))
+	failed := make(map[string]bool, len(deployments))
+
+	finished := make(chan *deploymentResult)
+	running := 0
+
+	finish := func(result *deploymentResult) {
+		result.finished = time.Now()
+		results[result.deployment.key()] = result
+		if result.err != nil || result.skipped {
+			failed[result.deployment.key()] = true
+		} else {
+			ready[result.deployment.key()] = true
+		}
+	}
+
+	pending := append([]*Deployment(nil), deployments...)
+	for len(pending) > 0 || running > 0 {
+		// start the first pending deployment whose dependencies are done till there's none left
+		for i := 0; i < len(pending); i++ {
+			d := pending[i]
+
+			if failedDependency := firstOf(d.DependsOn, failed); failedDependency != "" {
+				log.Warnf("skipping release [%s], its dependency [%s] failed", d.key(), failedDependency)
+				finish(&deploymentResult{deployment: d, started: time.Now(), skipped: true,
+					err: errors.Errorf("dependency [%s] failed", failedDependency)})
+			} else if allOf(d.DependsOn, ready) {
+				result := &deploymentResult{deployment: d, started: time.Now()}
+				result.action, result.err = p.startDeployment(ctx, d)
+				if result.err != nil || result.action == noAction || result.action == deletedAction {
+					finish(result)
+				} else {
+					running++
+					go func() {
+						result.err = p.awaitDeployment(ctx, result.deployment, result.action, timeouts)
+						finished <- result
+					}()
+				}
+			} else {
+				continue
+			}
+
+			pending = append(pending[:i], pending[i+1:]...)
+			// a finished deployment might unblock a deployment declared earlier
+			i = -1
+		}
+
+		if running == 0 {
+			break
+		}
+
+		finish(<-finished)
+		running--
+	}
+
+	return summarizeDeployments(deployments, results)
+}
+
+// firstOf returns the first of the keys set in the given set, empty if none of them is set
+func firstOf(keys []string, set map[string]bool) string {
+	for _, key := range keys {
+		if set[key] {
+			return key
+		}
+	}
+	return ""
+}
+
+// startDeployment sends the request installing, upgrading or deleting the deployment, the action taken is returned
+func (p *Plugin) startDeployment(ctx context.Context, d *Deployment) (string, error) {
+	log.Infof("checking deployment [%s]", d.Name)
+	deploymentExists, err := p.DeploymentExists(ctx, d)
+	if err != nil {
+		return checkedAction, errors.Wrap(err, "could not check deployment existence")
+	}
+
+	if d.State == createdState {
+		if err := p.resolveChartVersion(ctx, d); err != nil {
+			return resolvedAction, err
+		}
+	}
+
+	switch {
+	case d.State == createdState && !deploymentExists:
+		// the request might be accepted even if the run is canceled before the response arrives
+		p.createdDeployments = append(p.createdDeployments, d)
+		err = p.installDeployment(ctx, d)
+		if err != nil {
+			return installedAction, errors.Wrap(err, "deployment installation failed")
+		}
+		return installedAction, nil
+	case d.State == createdState:
+		log.Infof("deployment [%s] already exists, updating ...", d.Name)
+		err = p.updateDeployment(ctx, d)
+		if err != nil {
+			return upgradedAction, errors.Wrap(err, "deployment update failed")
+		}
+		return upgradedAction, nil
+	case d.State == deletedState && deploymentExists:
+		err = p.deleteDeployment(ctx, d)
+		if err != nil {
+			return deletedAction, errors.Wrap(err, "deployment deletion failed")
+		}
+		return deletedAction, nil
+	case d.State == deletedState:
+		log.Infof("deployment doesn't exist, nothing to delete: [%s]", d.Name)
+		return noAction, nil
+	default:
+		return checkedAction, errors.Errorf("invalid state: [%s] for deployment: [%s]", d.State, d.Name)
+	}
+}
+
+// awaitDeployment waits till the installed or upgraded deployment and its endpoints are ready
+func (p *Plugin) awaitDeployment(ctx context.Context, d *Deployment, action string, timeouts Timeouts) error {
+	exists := func(ctx context.Context) (bool, error) {
+		return p.DeploymentExists(ctx, d)
+	}
+	endpointsReady := func(ctx context.Context) (bool, error) {
+		return p.DeploymentReady(ctx, d)
+	}
+
+	err := p.waitForResource(ctx, fmt.Sprintf("release [%s]", d.key()), timeouts.Release,
+		withStatus(exists, "deployment not found"))
+	if err != nil {
+		log.Errorf("error while waiting for deployment %s", action)
+		return errors.Wrapf(err, "error while waiting for deployment %s", action)
+	}
+
+	err = p.waitForResource(ctx, fmt.Sprintf("endpoints of release [%s]", d.key()), timeouts.Endpoint,
+		withStatus(endpointsReady, "loadbalancer not ready"))
+	if err != nil {
+		log.Error("error while waiting for deployment loadbalancer to get ready")
+		return errors.Wrap(err, "error while waiting for deployment loadbalancer to get ready")
+	}
+
+	return nil
+}
+
+// summarizeDeployments logs the outcome of the deployments in the declared order, an error listing the failed
+// deployments is returned if any of them failed
+func summarizeDeployments(deployments []*Deployment, results map[string]*deploymentResult) error {
+	log.Info("deployments summary:")
+
+	var failed []string
+	for _, d := range deployments {
+		result, ok := results[d.key()]
+		switch {
+		case !ok:
+			// the run ended before the deployment was started
+			failed = append(failed, d.key())
+			log.Errorf("release [%s] (%s): not started", d.key(), d.chart())
+		case result.skipped:
+			failed = append(failed, d.key())
+			log.Warnf("release [%s] (%s): skipped, %s", d.key(), d.chart(), result.err.Error())
+		case result.err != nil:
+			failed = append(failed, d.key())
+			log.Errorf("release [%s] (%s): %s failed after %s: [%s]", d.key(), d.chart(), result.action,
+				result.finished.Sub(result.started).Round(time.Second), result.err.Error())
+		case result.action == noAction:
+			log.Infof("release [%s] (%s): %s", d.key(), d.chart(), result.action)
+		default:
+			log.Infof("release [%s] (%s): %s done in %s", d.key(), d.chart(), result.action,
+				result.finished.Sub(result.started).Round(time.Second))
+		}
+	}
+
+	if len(failed) > 0 {
+		// a single deployment fails with its own error
+		if result, ok := results[failed[0]]; ok && len(deployments) == 1 {
+			return result.err
+		}
+		return errors.Errorf("%d of %d deployments failed: %s", len(failed), len(deployments), listOf(failed))
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/helm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeployments(t *testing.T) {
+	deployments, err := parseDeployments(`[
+		{"name": "stable/mysql", "release_name": "db", "version": "0.8.2"},
+		{"name": "stable/wordpress", "release_name": "app", "state": "deleted", "depends_on": ["db"]}
+	]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Deployment{
+		{Name: "stable/mysql", ReleaseName: "db", Version: "0.8.2", State: createdState},
+		{Name: "stable/wordpress", ReleaseName: "app", State: deletedState, DependsOn: []string{"db"}},
+	}, deployments)
+
+	deployments, err = parseDeployments(`
+- name: stable/mysql
+  release_name: db
+  values:
+    pattern: '^v\d+$'
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Deployment{
+		{Name: "stable/mysql", ReleaseName: "db", State: createdState, Values: map[string]interface{}{"pattern": `^v\d+$`}},
+	}, deployments)
+
+	_, err = parseDeployments(`{"name": "stable/mysql"}`)
+	assert.NotNil(t, err)
+
+	_, err = parseDeployments("- name: stable/mysql\n  release_name: [db\n")
+	assert.EqualError(t, err, "could not parse deployments: invalid YAML: yaml: line 2: did not find expected ',' or ']'")
+}
+
+func TestCheckDeployments(t *testing.T) {
+	tests := []struct {
+		name        string
+		deployments []*Deployment
+		err         string
+	}{
+		{
+			name: "valid dependencies",
+			deployments: []*Deployment{
+				{Name: "stable/wordpress", ReleaseName: "app", DependsOn: []string{"db"}},
+				{Name: "stable/mysql", ReleaseName: "db"},
+				{Name: "stable/prometheus"},
+			},
+		},
+		{
+			name: "missing chart name",
+			deployments: []*Deployment{
+				{ReleaseName: "db"},
+			},
+			err: "the chart name of release [db] is missing",
+		},
+		{
+			name: "duplicate release",
+			deployments: []*Deployment{
+				{Name: "stable/mysql", ReleaseName: "db"},
+				{Name: "stable/postgresql", ReleaseName: "db"},
+			},
+			err: "release [db] is declared more than once",
+		},
+		{
+			name: "undeclared dependency",
+			deployments: []*Deployment{
+				{Name: "stable/wordpress", ReleaseName: "app", DependsOn: []string{"db"}},
+			},
+			err: "release [app] depends on the undeclared release [db]",
+		},
+		{
+			name: "cycle",
+			deployments: []*Deployment{
+				{Name: "stable/wordpress", ReleaseName: "app", DependsOn: []string{"cache"}},
+				{Name: "stable/redis", ReleaseName: "cache", DependsOn: []string{"db"}},
+				{Name: "stable/mysql", ReleaseName: "db", DependsOn: []string{"app"}},
+				{Name: "stable/prometheus", ReleaseName: "monitoring"},
+			},
+			err: "the dependencies of releases [app, cache, db] form a cycle",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkDeployments(test.deployments)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestPlugin_RunDeployments(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing string
+		posts   []string
+		err     string
+	}{
+		{
+			name:  "dependencies installed first",
+			posts: []string{"db", "monitoring", "app"},
+		},
+		{
+			name:    "dependent release skipped",
+			failing: "db",
+			posts:   []string{"db", "monitoring"},
+			err:     "2 of 3 deployments failed: [app, db]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var lock sync.Mutex
+			installed := map[string]bool{}
+			var posts []string
+
+			response := func(statusCode int, body string) *http.Response {
+				return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(bytes.NewBufferString(body))}
+			}
+
+			p := Plugin{
+				Config: Config{
+					OrgId:   1,
+					Cluster: &CustomCluster{CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"}},
+					Poll:    PollPolicy{Interval: 10 * time.Millisecond, Backoff: 1},
+				},
+				ApiCall: func(ctx context.Context, config *Config, rawUrl string, method string, body io.Reader) (*http.Response, error) {
+					lock.Lock()
+					defer lock.Unlock()
+
+					requestUrl, _ := url.Parse(rawUrl)
+					switch {
+					case method == http.MethodPost:
+						request := helm.CreateUpdateDeploymentRequest{}
+						assert.Nil(t, json.NewDecoder(body).Decode(&request))
+						posts = append(posts, request.ReleaseName)
+						if request.ReleaseName == test.failing {
+							return response(http.StatusInternalServerError, `{"message":"install failed"}`), nil
+						}
+						installed[request.ReleaseName] = true
+						return response(http.StatusCreated, `{}`), nil
+					case method == http.MethodHead:
+						release := requestUrl.Path[strings.LastIndex(requestUrl.Path, "/")+1:]
+						if installed[release] {
+							return response(http.StatusOK, ``), nil
+						}
+						return response(http.StatusNotFound, ``), nil
+					default:
+						// the releases have no public endpoints
+						return response(http.StatusNotFound, `{}`), nil
+					}
+				},
+			}
+
+			deployments := []*Deployment{
+				{Name: "stable/wordpress", ReleaseName: "app", State: createdState, DependsOn: []string{"db"}},
+				{Name: "stable/mysql", ReleaseName: "db", State: createdState},
+				{Name: "stable/prometheus", ReleaseName: "monitoring", State: createdState},
+			}
+			timeouts := Timeouts{}.orDefault(time.Minute)
+
+			err := p.runDeployments(context.Background(), deployments, timeouts)
+			assert.Equal(t, test.posts, posts)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/banzaicloud/drone-plugin-pipeline-client/client"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// kubernetesSecretType the type of the Pipeline secret holding the kubeconfig of an imported cluster
+	kubernetesSecretType = "kubernetes"
+	// kubernetesConfigKey the key of the kubeconfig in the kubernetes Pipeline secret
+	kubernetesConfigKey = "K8Sconfig"
+)
+
+// kubernetesSecretName the name of the Pipeline secret holding the kubeconfig of the imported cluster
+func kubernetesSecretName(clusterName string) string {
+	return fmt.Sprintf("%s-kubeconfig", clusterName)
+}
+
+// createKubernetesSecret registers the kubeconfig of the cluster to be imported as a Pipeline secret and sets its
+// identifier into the cluster creation request; the secret left behind by an earlier import is replaced, its kubeconfig
+// might be outdated
+func (p *Plugin) createKubernetesSecret(ctx context.Context) error {
+	kubeConfig, err := readKubeConfig(p.Config.Cluster.KubeConfig, p.Config.Cluster.KubeConfigFile, p.Build.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := p.deleteKubernetesSecret(ctx); err != nil {
+		return err
+	}
+
+	secretName := kubernetesSecretName(p.Config.Cluster.Name)
+	log.Infof("creating kubernetes secret: [%s]", secretName)
+
+	secret, err := p.pipelineClient().CreateSecret(ctx, p.Config.OrgId, &client.CreateSecretRequest{
+		Name: secretName,
+		Type: kubernetesSecretType,
+		Values: map[string]string{
+			kubernetesConfigKey: base64.StdEncoding.EncodeToString([]byte(kubeConfig)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("kubernetes secret [%s] created with id: [%s]", secretName, secret.Id)
+	p.Config.Cluster.SecretId = secret.Id
+	p.createdSecret = true
+
+	return nil
+}
+
+// deleteKubernetesSecret deletes the Pipeline secret holding the kubeconfig of the imported cluster, if there is any
+func (p *Plugin) deleteKubernetesSecret(ctx context.Context) error {
+	secretName := kubernetesSecretName(p.Config.Cluster.Name)
+
+	secrets, err := p.pipelineClient().Secrets(ctx, p.Config.OrgId, kubernetesSecretType)
+	if err != nil {
+		return errors.Wrapf(err, "could not look up kubernetes secret [%s]", secretName)
+	}
+
+	for _, secret := range secrets {
+		if secret.Name != secretName {
+			continue
+		}
+
+		log.Infof("deleting kubernetes secret [%s] with id: [%s]", secretName, secret.Id)
+		err := p.pipelineClient().DeleteSecret(ctx, p.Config.OrgId, secret.Id)
+		if err != nil && !client.IsNotFound(err) {
+			return errors.Wrapf(err, "could not delete kubernetes secret [%s]", secretName)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/drone-plugin-pipeline-client/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadKubeConfig(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+
+	err = ioutil.WriteFile(filepath.Join(workspace, "kubeconfig"), []byte("file config"), 0600)
+	assert.Nil(t, err)
+
+	kubeConfig, err := readKubeConfig("inline config", "kubeconfig", workspace)
+	assert.Nil(t, err)
+	assert.Equal(t, "inline config", kubeConfig, "the inline configuration must take precedence")
+
+	kubeConfig, err = readKubeConfig("", "kubeconfig", workspace)
+	assert.Nil(t, err)
+	assert.Equal(t, "file config", kubeConfig)
+
+	_, err = readKubeConfig("", "", workspace)
+	assert.NotNil(t, err)
+}
+
+func TestPlugin_CreateKubernetesSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets string
+		calls   []string
+	}{
+		{
+			name:    "no secret registered yet",
+			secrets: `[{"name":"other-cluster-kubeconfig","type":"kubernetes","id":"other-id"}]`,
+			calls: []string{
+				"GET http://pipeline/orgs/1/secrets?type=kubernetes",
+				"POST http://pipeline/orgs/1/secrets",
+			},
+		},
+		{
+			name:    "secret of an earlier import replaced",
+			secrets: `[{"name":"test-cluster-kubeconfig","type":"kubernetes","id":"old-id"}]`,
+			calls: []string{
+				"GET http://pipeline/orgs/1/secrets?type=kubernetes",
+				"DELETE http://pipeline/orgs/1/secrets/old-id",
+				"POST http://pipeline/orgs/1/secrets",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				calls         []string
+				secretRequest client.CreateSecretRequest
+			)
+
+			p := Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					calls = append(calls, method+" "+url)
+
+					response := &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}
+					switch method {
+					case http.MethodGet:
+						response.Body = ioutil.NopCloser(bytes.NewBufferString(test.secrets))
+					case http.MethodPost:
+						assert.Nil(t, json.NewDecoder(body).Decode(&secretRequest))
+						response.StatusCode = http.StatusCreated
+						response.Body = ioutil.NopCloser(bytes.NewBufferString(`{"name":"test-cluster-kubeconfig","type":"kubernetes","id":"secret-id"}`))
+					}
+					return response, nil
+				},
+				Config: Config{
+					Endpoint: "http://pipeline",
+					OrgId:    1,
+					Cluster: &CustomCluster{
+						CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"},
+						KubeConfig:           "kubeconfig",
+					},
+				},
+			}
+
+			assert.Nil(t, p.createKubernetesSecret(context.Background()))
+			assert.Equal(t, test.calls, calls)
+			assert.True(t, p.createdSecret)
+			assert.Equal(t, "secret-id", p.Config.Cluster.SecretId)
+			assert.Equal(t, "test-cluster-kubeconfig", secretRequest.Name)
+			assert.Equal(t, kubernetesSecretType, secretRequest.Type)
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("kubeconfig")), secretRequest.Values[kubernetesConfigKey])
+		})
+	}
+}
+
+func TestPlugin_DeleteKubernetesCluster(t *testing.T) {
+	tests := []struct {
+		name   string
+		exists bool
+		calls  []string
+	}{
+		{
+			name:   "secret deleted once the cluster is deleted",
+			exists: true,
+			calls: []string{
+				"GET http://pipeline/orgs/1/clusters/test-cluster?field=name",
+				"DELETE http://pipeline/orgs/1/clusters/test-cluster?field=name",
+				"GET http://pipeline/orgs/1/clusters/test-cluster?field=name",
+				"GET http://pipeline/orgs/1/secrets?type=kubernetes",
+				"DELETE http://pipeline/orgs/1/secrets/secret-id",
+			},
+		},
+		{
+			name: "secret left behind by a deleted cluster",
+			calls: []string{
+				"GET http://pipeline/orgs/1/clusters/test-cluster?field=name",
+				"GET http://pipeline/orgs/1/secrets?type=kubernetes",
+				"DELETE http://pipeline/orgs/1/secrets/secret-id",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			exists := test.exists
+
+			p := newTestKubernetesPlugin(func(method string, url string) *http.Response {
+				calls = append(calls, method+" "+url)
+				return kubernetesClusterResponse(method, url, &exists)
+			})
+			p.Config.Cluster.State = deletedState
+
+			assert.Nil(t, p.exec(context.Background()))
+			assert.Equal(t, test.calls, calls)
+		})
+	}
+}
+
+func TestPlugin_CleanupKubernetesSecret(t *testing.T) {
+	var calls []string
+	exists := true
+
+	p := newTestKubernetesPlugin(func(method string, url string) *http.Response {
+		calls = append(calls, method+" "+url)
+		return kubernetesClusterResponse(method, url, &exists)
+	})
+	p.createdCluster = true
+	p.createdSecret = true
+
+	p.cleanup()
+	assert.Equal(t, []string{
+		"DELETE http://pipeline/orgs/1/clusters/test-cluster?field=name",
+		"GET http://pipeline/orgs/1/clusters/test-cluster?field=name",
+		"GET http://pipeline/orgs/1/secrets?type=kubernetes",
+		"DELETE http://pipeline/orgs/1/secrets/secret-id",
+	}, calls, "the secret must be deleted once the cluster is deleted")
+
+	calls = nil
+	p.createdCluster = false
+
+	p.cleanup()
+	assert.Equal(t, []string{
+		"GET http://pipeline/orgs/1/secrets?type=kubernetes",
+		"DELETE http://pipeline/orgs/1/secrets/secret-id",
+	}, calls, "the secret must be deleted if the run is canceled before the cluster creation")
+}
+
+// newTestKubernetesPlugin returns a plugin working with the imported test-cluster, the API calls are answered by the
+// given function
+func newTestKubernetesPlugin(respond func(method string, url string) *http.Response) *Plugin {
+	return &Plugin{
+		ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+			return respond(method, url), nil
+		},
+		Config: Config{
+			Endpoint: "http://pipeline",
+			OrgId:    1,
+			Cluster: &CustomCluster{
+				CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster", Cloud: constants.Kubernetes},
+			},
+		},
+	}
+}
+
+// kubernetesClusterResponse answers the API calls on the imported test-cluster, which is gone once it's deleted
+func kubernetesClusterResponse(method string, url string, exists *bool) *http.Response {
+	response := func(statusCode int, body string) *http.Response {
+		return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(bytes.NewBufferString(body))}
+	}
+
+	switch {
+	case method == http.MethodGet && url == "http://pipeline/orgs/1/secrets?type=kubernetes":
+		return response(http.StatusOK, `[{"name":"test-cluster-kubeconfig","type":"kubernetes","id":"secret-id"}]`)
+	case method == http.MethodDelete && url == "http://pipeline/orgs/1/secrets/secret-id":
+		return response(http.StatusOK, "")
+	case method == http.MethodDelete:
+		*exists = false
+		return response(http.StatusAccepted, `{}`)
+	case method == http.MethodGet && *exists:
+		return response(http.StatusOK, `{"status":"RUNNING"}`)
+	}
+	return response(http.StatusNotFound, `{}`)
+}
+
+func TestNewCustomCluster_KubernetesWithoutKubeConfig(t *testing.T) {
+	for _, state := range []string{createdState, updatedState, deletedState} {
+		c := newTestContext(map[string]string{
+			"plugin.cluster.name":           "test-cluster",
+			"plugin.cluster.provider":       constants.Kubernetes,
+			"plugin.cluster.state":          state,
+			"plugin.kubernetes.config":      "",
+			"plugin.kubernetes.config_file": "",
+			"plugin.kubernetes.metadata":    "",
+		})
+
+		provider, err := getClusterProvider(constants.Kubernetes)
+		assert.Nil(t, err)
+
+		_, err = newCustomCluster(c, provider)
+		assert.Nil(t, err, "the kubeconfig must not be required to reuse or delete a cluster, state: %s", state)
+	}
+
+	p := Plugin{
+		Config: Config{
+			Cluster: &CustomCluster{
+				CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"},
+			},
+		},
+	}
+	assert.EqualError(t, p.createKubernetesSecret(context.Background()),
+		"either the kubeconfig or the kubeconfig file is required for kubernetes clusters")
+}
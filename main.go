@@ -2,39 +2,24 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"html/template"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig"
-	"github.com/banzaicloud/banzai-types/components"
-	"github.com/banzaicloud/banzai-types/components/amazon"
-	"github.com/banzaicloud/banzai-types/components/azure"
-	"github.com/banzaicloud/banzai-types/components/dummy"
-	"github.com/banzaicloud/banzai-types/components/google"
-	"github.com/banzaicloud/banzai-types/components/kubernetes"
 	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
 var (
 	version                string = ""
-	defaultAmazonImage     string = "ami-16bfeb6f"
 	defaultAmazonSpotPrice string = "0.2" //spot price for the default region/instance type
-
-	defaultInstanceType = map[string]string{
-		"amazon": "m4.xlarge",     // 4 vCPU, 16 GB RAM, General Purpose
-		"azure":  "Standard_B4ms", // 4 vCPU, 16 GB RAM, Burstable VM
-		"google": "n1-standard-4", // 4 vCPUs 15 GB RAM. Standard machine
-	}
-	defaultClusterLocation = map[string]string{
-		"amazon": "eu-west-1",
-		"azure":  "eastus",
-		"google": "us-central1-a",
-	}
 )
 
 const (
@@ -246,6 +231,36 @@ func main() {
 			Usage:  "API OAuth Token",
 			EnvVar: "PLUGIN_TOKEN,TOKEN",
 		},
+		cli.IntFlag{
+			Name:   "plugin.org.id",
+			Usage:  "identifier of the Pipeline organization to work in, no lookup is done if set",
+			EnvVar: "PLUGIN_ORG_ID",
+		},
+		cli.StringFlag{
+			Name:   "plugin.org.name",
+			Usage:  "name of the Pipeline organization to work in, the repository owner is used if not set",
+			EnvVar: "PLUGIN_ORG_NAME",
+		},
+		cli.StringFlag{
+			Name:   "plugin.org.mapping_file",
+			Usage:  "file mapping repository owners to Pipeline organizations (YAML or JSON), relative to the workspace",
+			EnvVar: "PLUGIN_ORG_MAPPING_FILE",
+		},
+		cli.BoolFlag{
+			Name:   "plugin.org.from_token",
+			Usage:  "use the organization the token was issued in instead of the repository owner",
+			EnvVar: "PLUGIN_ORG_FROM_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "plugin.username",
+			Usage:  "API username, used with the password if no token is provided",
+			EnvVar: "PLUGIN_USERNAME",
+		},
+		cli.StringFlag{
+			Name:   "plugin.password",
+			Usage:  "API password",
+			EnvVar: "PLUGIN_PASSWORD",
+		},
 		cli.StringFlag{
 			Name:   "plugin.cluster.name",
 			Usage:  "Kubernetes Cluster name",
@@ -258,10 +273,20 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "plugin.cluster.state",
-			Usage:  "K8S cluster desire state",
+			Usage:  "K8S cluster desire state (created, updated, deleted)",
 			EnvVar: "PLUGIN_CLUSTER_STATE",
 			Value:  "created",
 		},
+		cli.BoolTFlag{
+			Name:   "plugin.cluster.validate",
+			Usage:  "validate the cluster against the Pipeline cloud info before creating it (default: true)",
+			EnvVar: "PLUGIN_CLUSTER_VALIDATE",
+		},
+		cli.BoolTFlag{
+			Name:   "plugin.cluster.wait_for_deletion",
+			Usage:  "wait till the cluster is deleted (default: true)",
+			EnvVar: "PLUGIN_CLUSTER_WAIT_FOR_DELETION",
+		},
 		cli.StringFlag{
 			Name:   "plugin.cluster.provider",
 			Usage:  "K8S cluster provider",
@@ -273,6 +298,11 @@ func main() {
 			Usage:  "EC2 instance type",
 			EnvVar: "PLUGIN_AMAZON_NODE_INSTANCE_TYPE,PLUGIN_AZURE_NODE_INSTANCE_TYPE, PLUGIN_GOOGLE_INSTANCE_TYPE",
 		},
+		cli.StringFlag{
+			Name:   "plugin.cluster.node_pools",
+			Usage:  "Node pools of the cluster keyed by name (JSON or YAML), overrides the single node pool settings",
+			EnvVar: "PLUGIN_CLUSTER_NODE_POOLS",
+		},
 		cli.StringFlag{
 			Name:   "plugin.azure.resource_group",
 			Usage:  "Azure resource group name",
@@ -285,9 +315,8 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "plugin.azure.kubernetes_version",
-			Usage:  "Azure kubernetes version",
+			Usage:  "Azure kubernetes version, either an exact version or a selector like latest, 1.10 or ~1.10; the latest version is used for new clusters if not set",
 			EnvVar: "PLUGIN_AZURE_KUBERNETES_VERSION",
-			Value:  "1.9.2",
 		},
 		cli.StringFlag{
 			Name:   "plugin.azure.agent_name",
@@ -296,9 +325,8 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "plugin.amazon.node.image",
-			Usage:  "Amazon machine image id",
+			Usage:  "Amazon machine image id, looked up by location if not set",
 			EnvVar: "PLUGIN_AMAZON_NODE_IMAGE",
-			Value:  defaultAmazonImage,
 		},
 		cli.StringFlag{
 			Name:   "plugin.amazon.node.instance_type",
@@ -325,9 +353,8 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "plugin.amazon.master.image",
-			Usage:  "Amazon machine image id",
+			Usage:  "Amazon machine image id, looked up by location if not set",
 			EnvVar: "PLUGIN_AMAZON_MASTER_IMAGE",
-			Value:  defaultAmazonImage,
 		},
 		cli.StringFlag{
 			Name:   "plugin.amazon.master.instance_type",
@@ -344,6 +371,11 @@ func main() {
 			Usage:  "Specific deployment release name",
 			EnvVar: "PLUGIN_DEPLOYMENT_RELEASE_NAME",
 		},
+		cli.StringFlag{
+			Name:   "plugin.deployment.version",
+			Usage:  "Specific deployment chart version or semantic version constraint (e.g. ~0.8), the latest version is used if not set",
+			EnvVar: "PLUGIN_DEPLOYMENT_VERSION",
+		},
 		cli.StringFlag{
 			Name:   "plugin.deployment.state",
 			Usage:  "Specific deployment state",
@@ -357,9 +389,24 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "plugin.deployment.values",
-			Usage:  "Specific deployment values",
+			Usage:  "Specific deployment values (JSON or YAML)",
 			EnvVar: "PLUGIN_DEPLOYMENT_VALUES",
 		},
+		cli.StringSliceFlag{
+			Name:   "plugin.deployment.values_files",
+			Usage:  "YAML or JSON values files relative to the workspace, merged in order before the deployment values",
+			EnvVar: "PLUGIN_DEPLOYMENT_VALUES_FILES",
+		},
+		cli.BoolFlag{
+			Name:   "plugin.deployment.strict_secrets",
+			Usage:  "Fail if a secret placeholder of the deployment values has no secret, otherwise it's replaced by \"<no value>\"",
+			EnvVar: "PLUGIN_DEPLOYMENT_STRICT_SECRETS",
+		},
+		cli.StringFlag{
+			Name:   "plugin.deployments",
+			Usage:  "list of deployments (name, release_name, version, state, reuse_values, values_files, values, depends_on) installed along with plugin.deployment (JSON or YAML)",
+			EnvVar: "PLUGIN_DEPLOYMENTS",
+		},
 		cli.StringFlag{
 			Name:   "plugin.log.level",
 			Usage:  "Specific log level (debug,info,warn)",
@@ -379,9 +426,8 @@ func main() {
 		},
 		cli.StringFlag{
 			Name:   "plugin.google.gke.version",
-			Usage:  "The kubernetes version of the GKE",
+			Usage:  "The kubernetes version of the GKE, either an exact version or a selector like latest, 1.10 or ~1.10; the latest version is used for new clusters if not set",
 			EnvVar: "PLUGIN_GOOGLE_GKE_VERSION",
-			Value:  "1.9.4-gke.1",
 		},
 		cli.IntFlag{
 			Name:   "plugin.google.node.count",
@@ -394,6 +440,31 @@ func main() {
 			Usage:  "The service account the  cluster instance are run as",
 			EnvVar: "PLUGIN_GOOGLE_SERVICE_ACCOUNT",
 		},
+		cli.IntFlag{
+			Name:   "plugin.dummy.node.count",
+			Usage:  fmt.Sprintf("The number of nodes of the dummy cluster, %d if not set", dummyDefaultNodeCount),
+			EnvVar: "PLUGIN_DUMMY_NODE_COUNT",
+		},
+		cli.StringFlag{
+			Name:   "plugin.dummy.kubernetes_version",
+			Usage:  fmt.Sprintf("The kubernetes version of the dummy cluster, %s if not set", dummyDefaultKubernetesVersion),
+			EnvVar: "PLUGIN_DUMMY_KUBERNETES_VERSION",
+		},
+		cli.StringFlag{
+			Name:   "plugin.kubernetes.config",
+			Usage:  "The kubeconfig of the cluster to be imported",
+			EnvVar: "PLUGIN_KUBERNETES_CONFIG",
+		},
+		cli.StringFlag{
+			Name:   "plugin.kubernetes.config_file",
+			Usage:  "The kubeconfig file of the cluster to be imported, relative to the workspace",
+			EnvVar: "PLUGIN_KUBERNETES_CONFIG_FILE",
+		},
+		cli.StringFlag{
+			Name:   "plugin.kubernetes.metadata",
+			Usage:  "The metadata of the imported cluster (JSON or YAML)",
+			EnvVar: "PLUGIN_KUBERNETES_METADATA",
+		},
 		cli.StringFlag{
 			Name:   "plugin.secret.id",
 			Usage:  "The secret id",
@@ -405,6 +476,117 @@ func main() {
 			EnvVar: "PLUGIN_RESOURCE_TIMEOUT",
 			Value:  2 * 60 * 60, // 2 hours
 		},
+		cli.Int64Flag{
+			Name:   "plugin.timeout.cluster_create",
+			Usage:  "time limit of the cluster creation and update (in seconds), plugin.resource.timeout is used if not set",
+			EnvVar: "PLUGIN_TIMEOUT_CLUSTER_CREATE",
+		},
+		cli.Int64Flag{
+			Name:   "plugin.timeout.cluster_delete",
+			Usage:  "time limit of the cluster deletion (in seconds), plugin.resource.timeout is used if not set",
+			EnvVar: "PLUGIN_TIMEOUT_CLUSTER_DELETE",
+		},
+		cli.Int64Flag{
+			Name:   "plugin.timeout.helm",
+			Usage:  "time limit of helm getting ready on the cluster (in seconds), plugin.resource.timeout is used if not set",
+			EnvVar: "PLUGIN_TIMEOUT_HELM",
+		},
+		cli.Int64Flag{
+			Name:   "plugin.timeout.release",
+			Usage:  "time limit of the release installation or upgrade (in seconds), plugin.resource.timeout is used if not set",
+			EnvVar: "PLUGIN_TIMEOUT_RELEASE",
+		},
+		cli.Int64Flag{
+			Name:   "plugin.timeout.endpoint",
+			Usage:  "time limit of the release endpoints getting ready (in seconds), plugin.resource.timeout is used if not set",
+			EnvVar: "PLUGIN_TIMEOUT_ENDPOINT",
+		},
+		cli.Int64Flag{
+			Name:   "plugin.timeout.deadline",
+			Usage:  "time limit of the whole step (in seconds), not limited if not set",
+			EnvVar: "PLUGIN_TIMEOUT_DEADLINE",
+		},
+		cli.StringFlag{
+			Name:   "plugin.tls.ca_cert",
+			Usage:  "PEM encoded CA certificates the Pipeline API certificate is verified with, in addition to the system ones",
+			EnvVar: "PLUGIN_TLS_CA_CERT",
+		},
+		cli.StringFlag{
+			Name:   "plugin.tls.ca_cert_file",
+			Usage:  "file holding the CA certificates, used if plugin.tls.ca_cert is not set",
+			EnvVar: "PLUGIN_TLS_CA_CERT_FILE",
+		},
+		cli.StringFlag{
+			Name:   "plugin.tls.client_cert",
+			Usage:  "PEM encoded client certificate for mutual TLS authentication",
+			EnvVar: "PLUGIN_TLS_CLIENT_CERT",
+		},
+		cli.StringFlag{
+			Name:   "plugin.tls.client_cert_file",
+			Usage:  "file holding the client certificate, used if plugin.tls.client_cert is not set",
+			EnvVar: "PLUGIN_TLS_CLIENT_CERT_FILE",
+		},
+		cli.StringFlag{
+			Name:   "plugin.tls.client_key",
+			Usage:  "PEM encoded key of the client certificate",
+			EnvVar: "PLUGIN_TLS_CLIENT_KEY",
+		},
+		cli.StringFlag{
+			Name:   "plugin.tls.client_key_file",
+			Usage:  "file holding the key of the client certificate, used if plugin.tls.client_key is not set",
+			EnvVar: "PLUGIN_TLS_CLIENT_KEY_FILE",
+		},
+		cli.BoolFlag{
+			Name:   "plugin.tls.insecure_skip_verify",
+			Usage:  "skip the verification of the Pipeline API certificate, for development only",
+			EnvVar: "PLUGIN_TLS_INSECURE_SKIP_VERIFY",
+		},
+		cli.StringFlag{
+			Name:   "plugin.proxy",
+			Usage:  "URL of the HTTP(S) proxy the Pipeline API is reached through, the proxy environment variables are used if not set",
+			EnvVar: "PLUGIN_PROXY",
+		},
+		cli.Int64Flag{
+			Name:   "plugin.request.timeout",
+			Usage:  "time limit of a single Pipeline API request (in seconds), 0 means no limit",
+			EnvVar: "PLUGIN_REQUEST_TIMEOUT",
+			Value:  60,
+		},
+		cli.IntFlag{
+			Name:   "plugin.retry.attempts",
+			Usage:  "number of attempts of the API calls failing with transient errors, 1 disables retries",
+			EnvVar: "PLUGIN_RETRY_ATTEMPTS",
+			Value:  5,
+		},
+		cli.Int64Flag{
+			Name:   "plugin.retry.budget",
+			Usage:  "total time the attempts of a single API call may take (in seconds)",
+			EnvVar: "PLUGIN_RETRY_BUDGET",
+			Value:  2 * 60, // 2 minutes
+		},
+		cli.Int64Flag{
+			Name:   "plugin.poll.interval",
+			Usage:  "delay between the first two checks of a resource being waited for (in seconds)",
+			EnvVar: "PLUGIN_POLL_INTERVAL",
+			Value:  5,
+		},
+		cli.Float64Flag{
+			Name:   "plugin.poll.backoff",
+			Usage:  "factor the delay between two checks of a resource is multiplied by after every check, 1 keeps the delay constant",
+			EnvVar: "PLUGIN_POLL_BACKOFF",
+			Value:  1.5,
+		},
+		cli.Int64Flag{
+			Name:   "plugin.poll.max_interval",
+			Usage:  "upper limit of the delay between two checks of a resource (in seconds)",
+			EnvVar: "PLUGIN_POLL_MAX_INTERVAL",
+			Value:  30,
+		},
+		cli.BoolFlag{
+			Name:   "plugin.cleanup_on_cancel",
+			Usage:  "delete the cluster or deployment created in this run if the build is canceled",
+			EnvVar: "PLUGIN_CLEANUP_ON_CANCEL",
+		},
 		cli.StringFlag{
 			Name:   "plugin.profile.name",
 			Usage:  "the name of the profile to be used to create the cluster",
@@ -414,25 +596,13 @@ func main() {
 	app.Run(os.Args)
 }
 
-func setDefaults(c *cli.Context) {
-
-	provider := c.String("plugin.cluster.provider")
-
-	if c.String("plugin.node.instance_type") == "" {
-		c.Set("plugin.node.instance_type", defaultInstanceType[provider])
-	}
-
-	if c.String("plugin.amazon.master.instance_type") == "" {
-		c.Set("plugin.amazon.master.instance_type", defaultInstanceType[provider])
-	}
+func setDefaults(c *cli.Context, provider clusterProvider) {
 
 	if c.String("plugin.cluster.location") == "" {
-		c.Set("plugin.cluster.location", defaultClusterLocation[provider])
+		c.Set("plugin.cluster.location", provider.defaultLocation())
 	}
 
-	if c.String("plugin.azure.agent_name") == "" {
-		c.Set("plugin.azure.agent_name", c.String("plugin.cluster.name"))
-	}
+	provider.setDefaults(c)
 
 }
 
@@ -460,23 +630,72 @@ func run(c *cli.Context) error {
 	}
 
 	processLogLevel(c)
-	setDefaults(c)
+
+	provider, err := getClusterProvider(c.String("plugin.cluster.provider"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	setDefaults(c, provider)
 
 	var deploymentValues map[string]interface{}
 	var deploymentValStr = c.String("plugin.deployment.values")
 
 	if deploymentValStr != "" {
+		deploymentValues, err = parseValues(deploymentValStr)
+		if err != nil {
+			log.Fatalf("unable to parse deployment values: %s", err.Error())
+		}
+	}
 
-		err := json.Unmarshal([]byte(processDeploymentSecrets(strings.Replace(deploymentValStr, "\\", "", -1), items)), &deploymentValues)
+	strictSecrets := c.Bool("plugin.deployment.strict_secrets")
+	deploymentValues, err = buildValues(c.StringSlice("plugin.deployment.values_files"), deploymentValues,
+		c.String("build.path"), items, strictSecrets)
+	if err != nil {
+		log.Fatalf("unable to process deployment values: [%s]", err.Error())
+	}
+	log.Debugf("deployment values: %+v", deploymentValues)
 
-		log.Debugf("deployment values: %+v", deploymentValues)
+	deployments, err := parseDeployments(c.String("plugin.deployments"))
+	if err != nil {
+		log.Fatalf("unable to parse deployments: [%s]", err.Error())
+	}
 
+	for _, d := range deployments {
+		d.Values, err = buildValues(d.ValuesFiles, d.Values, c.String("build.path"), items, strictSecrets)
 		if err != nil {
-			log.Fatalf("unable to parse deployment values: [%s]", err.Error())
+			log.Fatalf("unable to process values of deployment [%s]: [%s]", d.key(), err.Error())
 		}
 	}
 
-	const defaultNodePoolName = "default-node-pool"
+	cluster, err := newCustomCluster(c, provider)
+	if err != nil {
+		log.Fatalf("unable to process cluster settings: [%s]", err.Error())
+	}
+
+	orgMapping, err := readOrgMapping(c.String("plugin.org.mapping_file"), c.String("build.path"))
+	if err != nil {
+		log.Fatalf("unable to process organization settings: [%s]", err.Error())
+	}
+
+	httpClient, err := newHttpClient(TransportSettings{
+		CACert:             c.String("plugin.tls.ca_cert"),
+		CACertFile:         c.String("plugin.tls.ca_cert_file"),
+		ClientCert:         c.String("plugin.tls.client_cert"),
+		ClientCertFile:     c.String("plugin.tls.client_cert_file"),
+		ClientKey:          c.String("plugin.tls.client_key"),
+		ClientKeyFile:      c.String("plugin.tls.client_key_file"),
+		InsecureSkipVerify: c.Bool("plugin.tls.insecure_skip_verify"),
+		Proxy:              c.String("plugin.proxy"),
+		Timeout:            time.Duration(c.Int64("plugin.request.timeout")) * time.Second,
+	})
+	if err != nil {
+		log.Fatalf("unable to process transport settings: [%s]", err.Error())
+	}
+
+	pollPolicy, err := newPollPolicy(c)
+	if err != nil {
+		log.Fatalf("unable to process poll settings: [%s]", err.Error())
+	}
 
 	plugin := Plugin{
 		ApiCall: ApiCall,
@@ -516,112 +735,96 @@ func run(c *cli.Context) error {
 		Config: Config{
 			Endpoint:    c.String("plugin.endpoint"),
 			Token:       c.String("plugin.token"),
+			Username:    c.String("plugin.username"),
+			Password:    c.String("plugin.password"),
 			WaitTimeout: c.Int64("plugin.resource.timeout"),
 
-			Cluster: &CustomCluster{
-				CreateClusterRequest: &components.CreateClusterRequest{
-					Name:     c.String("plugin.cluster.name"),
-					Location: c.String("plugin.cluster.location"),
-					Cloud:    c.String("plugin.cluster.provider"),
-					SecretId: c.String("plugin.secret.id"),
-					Properties: struct {
-						CreateClusterAmazon *amazon.CreateClusterAmazon  `json:"amazon,omitempty"`
-						CreateClusterAzure  *azure.CreateClusterAzure    `json:"azure,omitempty"`
-						CreateClusterGoogle *google.CreateClusterGoogle  `json:"google,omitempty"`
-						CreateClusterDummy  *dummy.CreateClusterDummy    `json:"dummy,omitempty"`
-						CreateKubernetes    *kubernetes.CreateKubernetes `json:"kubernetes,omitempty"`
-					}{
-						CreateClusterAmazon: &amazon.CreateClusterAmazon{
-							NodePools: map[string]*amazon.AmazonNodePool{
-								defaultNodePoolName: &amazon.AmazonNodePool{
-									Image:        c.String("plugin.amazon.node.image"),
-									MaxCount:     c.Int("plugin.amazon.node.max_count"),
-									MinCount:     c.Int("plugin.amazon.node.min_count"),
-									InstanceType: c.String("plugin.node.instance_type"),
-									SpotPrice:    c.String("plugin.amazon.node.spot_price"),
-								},
-							},
-							Master: &amazon.CreateAmazonMaster{
-								InstanceType: c.String("plugin.amazon.master.instance_type"),
-								Image:        c.String("plugin.amazon.master.image"),
-							},
-						},
-						CreateClusterAzure: &azure.CreateClusterAzure{
-							NodePools: map[string]*azure.NodePoolCreate{
-								defaultNodePoolName: &azure.NodePoolCreate{
-									Count:            c.Int("plugin.azure.node.count"),
-									NodeInstanceType: c.String("plugin.node.instance_type"),
-								},
-							},
-							KubernetesVersion: c.String("plugin.azure.kubernetes_version"),
-							ResourceGroup:     c.String("plugin.azure.resource_group"),
-						},
-						CreateClusterGoogle: &google.CreateClusterGoogle{
-							Project: c.String("plugin.google.project"),
-							Master: &google.Master{
-								Version: c.String("plugin.google.gke.version"),
-							},
-							NodeVersion: c.String("plugin.google.gke.version"),
-							NodePools: map[string]*google.NodePool{
-								defaultNodePoolName: &google.NodePool{
-									NodeInstanceType: c.String("plugin.node.instance_type"),
-									Count:            c.Int("plugin.google.node.count"),
-								},
-							},
-						},
-						CreateClusterDummy: nil,
-					},
-				},
-				State: c.String("plugin.cluster.state"),
+			OrgId:        c.Int("plugin.org.id"),
+			OrgName:      c.String("plugin.org.name"),
+			OrgMapping:   orgMapping,
+			OrgFromToken: c.Bool("plugin.org.from_token"),
+
+			WaitForDeletion: c.BoolT("plugin.cluster.wait_for_deletion"),
+			ValidateCluster: c.BoolT("plugin.cluster.validate"),
+			CleanupOnCancel: c.Bool("plugin.cleanup_on_cancel"),
+			Retry: RetryPolicy{
+				MaxAttempts: c.Int("plugin.retry.attempts"),
+				Budget:      time.Duration(c.Int64("plugin.retry.budget")) * time.Second,
+			},
+			Timeouts: Timeouts{
+				ClusterCreate: time.Duration(c.Int64("plugin.timeout.cluster_create")) * time.Second,
+				ClusterDelete: time.Duration(c.Int64("plugin.timeout.cluster_delete")) * time.Second,
+				Helm:          time.Duration(c.Int64("plugin.timeout.helm")) * time.Second,
+				Release:       time.Duration(c.Int64("plugin.timeout.release")) * time.Second,
+				Endpoint:      time.Duration(c.Int64("plugin.timeout.endpoint")) * time.Second,
+				Deadline:      time.Duration(c.Int64("plugin.timeout.deadline")) * time.Second,
 			},
+			Poll:       pollPolicy,
+			HttpClient: httpClient,
+
+			Cluster: cluster,
 			Deployment: &Deployment{
 				Name:        c.String("plugin.deployment.name"),
 				ReleaseName: c.String("plugin.deployment.release_name"),
+				Version:     c.String("plugin.deployment.version"),
 				State:       c.String("plugin.deployment.state"),
 				ReuseValues: c.Bool("plugin.deployment.reuse_values"),
 				Values:      deploymentValues,
 			},
+			Deployments: deployments,
 		},
 	}
 
-	plugin.processServiceAccount(c)
 	plugin.processProfile(c)
 
-	err := plugin.Exec()
+	ctx, cancel := signalContext(context.Background())
+	defer cancel()
+
+	err = plugin.Exec(ctx)
 	if err != nil {
+		if ctx.Err() == context.Canceled {
+			log.Errorf("canceled: [%s]", err.Error())
+			os.Exit(exitCodeCanceled)
+		}
 		log.Fatal(err)
 	}
 	return nil
 }
 
-// Replaces placeholders in the deployment values Go template
-// Fails if invalid template provided as deployment value.
-func processDeploymentSecrets(deploymentValuesStr string, pluginEnv map[string]string) string {
+// Replaces placeholders in a deployment value Go template, the sprig functions (e.g. toJson, quote) are available and
+// the secrets are substituted as they are, without HTML escaping. In strict mode a placeholder without a secret is an
+// error instead of being replaced by "<no value>".
+// Returns an error if an invalid template is provided as deployment value.
+func processDeploymentSecrets(deploymentValueStr string, pluginEnv map[string]string, strict bool) (string, error) {
 	log.Debug("filling secrets in deployment values...")
 
-	deplValTpl, err := template.New("depValTpl").Funcs(sprig.FuncMap()).Parse(deploymentValuesStr)
+	missingKey := "missingkey=default"
+	if strict {
+		missingKey = "missingkey=error"
+	}
+
+	deplValTpl, err := template.New("depValTpl").Funcs(sprig.TxtFuncMap()).Option(missingKey).Parse(deploymentValueStr)
 	if err != nil {
-		log.Fatalf("failed to create template: [%s]", err.Error())
+		return "", errors.Wrap(err, "failed to create template")
 	}
 
 	var tpl bytes.Buffer
-	err = deplValTpl.ExecuteTemplate(&tpl, "depValTpl", pluginEnv)
+	err = deplValTpl.Execute(&tpl, pluginEnv)
 	if err != nil {
-		log.Fatalf("failed to execute template: [%s]", err.Error())
+		return "", errors.Wrap(err, "failed to execute template")
 	}
 	log.Debug("secrets filled in deployment values.")
-	return tpl.String()
+	return tpl.String(), nil
 }
 
-func (plugin *Plugin) processServiceAccount(c *cli.Context) {
-	serviceAccount := c.String("plugin.google.service.account")
-
-	if len(serviceAccount) > 0 {
-		for i, _ := range plugin.Config.Cluster.Properties.CreateClusterGoogle.NodePools {
-			plugin.Config.Cluster.Properties.CreateClusterGoogle.NodePools[i].ServiceAccount = serviceAccount
-		}
+// workspacePath resolves the path of a file relative to the workspace, absolute paths are kept
+func workspacePath(file string, workspace string) string {
+	if filepath.IsAbs(file) {
+		return file
 	}
+	return filepath.Join(workspace, file)
 }
+
 func (plugin *Plugin) processProfile(c *cli.Context) {
 	profileName := c.String("plugin.profile.name")
 	log.Debugf("using profile: [%s]", profileName)
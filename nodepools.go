@@ -0,0 +1,193 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/banzaicloud/banzai-types/components/amazon"
+	"github.com/banzaicloud/banzai-types/components/azure"
+	"github.com/banzaicloud/banzai-types/components/google"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultNodePoolName = "default-node-pool"
+
+type (
+	// NodePool cloud independent description of a node pool, converted to the provider specific node pool
+	// when the cluster creation request is assembled
+	NodePool struct {
+		InstanceType   string `json:"instance_type" yaml:"instance_type"`
+		Count          int    `json:"count" yaml:"count"`
+		MinCount       int    `json:"min_count" yaml:"min_count"`
+		MaxCount       int    `json:"max_count" yaml:"max_count"`
+		SpotPrice      string `json:"spot_price" yaml:"spot_price"`
+		Image          string `json:"image" yaml:"image"`
+		ServiceAccount string `json:"service_account" yaml:"service_account"`
+	}
+
+	// NodePools node pools of the cluster keyed by name
+	NodePools map[string]*NodePool
+)
+
+// parseNodePools parses the node pool specification; both YAML and JSON documents are accepted
+func parseNodePools(nodePoolsStr string) (NodePools, error) {
+	nodePools := NodePools{}
+
+	err := yaml.UnmarshalStrict([]byte(nodePoolsStr), &nodePools)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse node pools")
+	}
+
+	if len(nodePools) == 0 {
+		return nil, errors.New("at least one node pool is required")
+	}
+
+	for name, nodePool := range nodePools {
+		if nodePool == nil {
+			return nil, errors.Errorf("node pool [%s] is empty", name)
+		}
+	}
+
+	return nodePools, nil
+}
+
+// withDefaults fills the unset fields of every node pool with the values of the provided node pool. The autoscaling
+// bounds are completed consistently: a missing bound is derived from the other bound or the count of the node pool, so
+// that setting only one of them doesn't result in an invalid range.
+func (nodePools NodePools) withDefaults(defaults NodePool) NodePools {
+	for _, nodePool := range nodePools {
+		if nodePool.InstanceType == "" {
+			nodePool.InstanceType = defaults.InstanceType
+		}
+
+		switch {
+		case nodePool.MinCount == 0 && nodePool.MaxCount == 0 && nodePool.Count > 0:
+			nodePool.MinCount = nodePool.Count
+			nodePool.MaxCount = nodePool.Count
+		case nodePool.MinCount == 0 && nodePool.MaxCount == 0:
+			nodePool.MinCount = defaults.MinCount
+			nodePool.MaxCount = defaults.MaxCount
+		case nodePool.MinCount == 0:
+			nodePool.MinCount = minInt(defaults.MinCount, nodePool.MaxCount)
+		case nodePool.MaxCount == 0:
+			nodePool.MaxCount = maxInt(nodePool.MinCount, maxInt(nodePool.Count, defaults.MaxCount))
+		}
+
+		if nodePool.Count == 0 {
+			nodePool.Count = defaults.Count
+		}
+		if nodePool.SpotPrice == "" {
+			nodePool.SpotPrice = defaults.SpotPrice
+		}
+		if nodePool.Image == "" {
+			nodePool.Image = defaults.Image
+		}
+		if nodePool.ServiceAccount == "" {
+			nodePool.ServiceAccount = defaults.ServiceAccount
+		}
+	}
+	return nodePools
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// validate checks the node pools against the constraints of the given cloud provider
+func (nodePools NodePools) validate(cloud string) error {
+	switch cloud {
+	case constants.Amazon, constants.Azure, constants.Google:
+	default:
+		// node pools are not sent for other clouds
+		return nil
+	}
+
+	if len(nodePools) == 0 {
+		return errors.New("at least one node pool is required")
+	}
+
+	// iterate in a stable order so that the reported error is deterministic
+	names := make([]string, 0, len(nodePools))
+	for name := range nodePools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		nodePool := nodePools[name]
+
+		if nodePool.InstanceType == "" {
+			return errors.Errorf("node pool [%s]: instance type is required", name)
+		}
+
+		switch cloud {
+		case constants.Amazon:
+			if nodePool.MinCount < 1 {
+				return errors.Errorf("node pool [%s]: min count must be at least 1", name)
+			}
+			if nodePool.MaxCount < nodePool.MinCount {
+				return errors.Errorf("node pool [%s]: max count must not be less than min count", name)
+			}
+		case constants.Azure, constants.Google:
+			if nodePool.Count < 1 {
+				return errors.Errorf("node pool [%s]: count must be at least 1", name)
+			}
+			if cloud == constants.Azure && nodePool.ServiceAccount != "" {
+				return errors.Errorf("node pool [%s]: service account is not supported by [%s]", name, cloud)
+			}
+		}
+	}
+
+	return nil
+}
+
+// amazon converts the node pools to amazon node pools
+func (nodePools NodePools) amazon() map[string]*amazon.AmazonNodePool {
+	amazonNodePools := make(map[string]*amazon.AmazonNodePool, len(nodePools))
+	for name, nodePool := range nodePools {
+		amazonNodePools[name] = &amazon.AmazonNodePool{
+			InstanceType: nodePool.InstanceType,
+			SpotPrice:    nodePool.SpotPrice,
+			MinCount:     nodePool.MinCount,
+			MaxCount:     nodePool.MaxCount,
+			Image:        nodePool.Image,
+		}
+	}
+	return amazonNodePools
+}
+
+// azure converts the node pools to azure node pools
+func (nodePools NodePools) azure() map[string]*azure.NodePoolCreate {
+	azureNodePools := make(map[string]*azure.NodePoolCreate, len(nodePools))
+	for name, nodePool := range nodePools {
+		azureNodePools[name] = &azure.NodePoolCreate{
+			Count:            nodePool.Count,
+			NodeInstanceType: nodePool.InstanceType,
+		}
+	}
+	return azureNodePools
+}
+
+// google converts the node pools to google node pools
+func (nodePools NodePools) google() map[string]*google.NodePool {
+	googleNodePools := make(map[string]*google.NodePool, len(nodePools))
+	for name, nodePool := range nodePools {
+		googleNodePools[name] = &google.NodePool{
+			Count:            nodePool.Count,
+			NodeInstanceType: nodePool.InstanceType,
+			ServiceAccount:   nodePool.ServiceAccount,
+		}
+	}
+	return googleNodePools
+}
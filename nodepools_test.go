@@ -0,0 +1,154 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNodePools(t *testing.T) {
+	tests := []struct {
+		name         string
+		nodePoolsStr string
+		nodePools    NodePools
+		err          bool
+	}{
+		{
+			name:         "json node pools",
+			nodePoolsStr: `{"system":{"instance_type":"m4.large","min_count":1,"max_count":2},"batch":{"instance_type":"c5.4xlarge","spot_price":"0.5"}}`,
+			nodePools: NodePools{
+				"system": {InstanceType: "m4.large", MinCount: 1, MaxCount: 2},
+				"batch":  {InstanceType: "c5.4xlarge", SpotPrice: "0.5"},
+			},
+		},
+		{
+			name:         "yaml node pools",
+			nodePoolsStr: "system:\n  instance_type: n1-standard-2\n  count: 3\n  service_account: sa@project\n",
+			nodePools: NodePools{
+				"system": {InstanceType: "n1-standard-2", Count: 3, ServiceAccount: "sa@project"},
+			},
+		},
+		{
+			name:         "unknown field",
+			nodePoolsStr: `{"system":{"instance":"m4.large"}}`,
+			err:          true,
+		},
+		{
+			name:         "empty node pool",
+			nodePoolsStr: "system:\n",
+			err:          true,
+		},
+		{
+			name:         "no node pools",
+			nodePoolsStr: "{}",
+			err:          true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodePools, err := parseNodePools(test.nodePoolsStr)
+			if test.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.nodePools, nodePools)
+		})
+	}
+}
+
+func TestNodePools_WithDefaults(t *testing.T) {
+	defaults := NodePool{InstanceType: "m4.xlarge", Count: 1, MinCount: 1, MaxCount: 2}
+
+	tests := []struct {
+		name     string
+		nodePool NodePool
+		expected NodePool
+	}{
+		{
+			name:     "defaults",
+			expected: NodePool{InstanceType: "m4.xlarge", Count: 1, MinCount: 1, MaxCount: 2},
+		},
+		{
+			name:     "min count only",
+			nodePool: NodePool{MinCount: 3},
+			expected: NodePool{InstanceType: "m4.xlarge", Count: 1, MinCount: 3, MaxCount: 3},
+		},
+		{
+			name:     "min count and count",
+			nodePool: NodePool{MinCount: 3, Count: 5},
+			expected: NodePool{InstanceType: "m4.xlarge", Count: 5, MinCount: 3, MaxCount: 5},
+		},
+		{
+			name:     "max count only",
+			nodePool: NodePool{MaxCount: 5},
+			expected: NodePool{InstanceType: "m4.xlarge", Count: 1, MinCount: 1, MaxCount: 5},
+		},
+		{
+			name:     "count only",
+			nodePool: NodePool{Count: 4},
+			expected: NodePool{InstanceType: "m4.xlarge", Count: 4, MinCount: 4, MaxCount: 4},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nodePool := test.nodePool
+			nodePools := NodePools{"pool": &nodePool}.withDefaults(defaults)
+			assert.Equal(t, test.expected, *nodePools["pool"])
+			assert.Nil(t, nodePools.validate(constants.Amazon))
+		})
+	}
+}
+
+func TestNodePools_Validate(t *testing.T) {
+	defaults := NodePool{InstanceType: "m4.xlarge", Count: 1, MinCount: 1, MaxCount: 1, Image: "ami-16bfeb6f"}
+
+	tests := []struct {
+		name      string
+		cloud     string
+		nodePools NodePools
+		err       string
+	}{
+		{
+			name:      "amazon defaults",
+			cloud:     constants.Amazon,
+			nodePools: NodePools{defaultNodePoolName: {}},
+		},
+		{
+			name:      "amazon min count only",
+			cloud:     constants.Amazon,
+			nodePools: NodePools{"batch": {MinCount: 3}},
+		},
+		{
+			name:      "amazon min greater than max",
+			cloud:     constants.Amazon,
+			nodePools: NodePools{"batch": {MinCount: 3, MaxCount: 2}},
+			err:       "node pool [batch]: max count must not be less than min count",
+		},
+		{
+			name:      "azure service account",
+			cloud:     constants.Azure,
+			nodePools: NodePools{"system": {ServiceAccount: "sa"}},
+			err:       "node pool [system]: service account is not supported by [azure]",
+		},
+		{
+			name:      "google multiple pools",
+			cloud:     constants.Google,
+			nodePools: NodePools{"system": {}, "batch": {InstanceType: "n1-highcpu-16", Count: 4}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.nodePools.withDefaults(defaults).validate(test.cloud)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// readOrgMapping reads the file mapping repository owners to Pipeline organizations, the file is resolved relative to
+// the workspace; both YAML and JSON documents are accepted
+func readOrgMapping(mappingFile string, workspace string) (map[string]string, error) {
+	if mappingFile == "" {
+		return nil, nil
+	}
+
+	mappingFile = workspacePath(mappingFile, workspace)
+
+	mappingBytes, err := ioutil.ReadFile(mappingFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read organization mapping file: [%s]", mappingFile)
+	}
+
+	orgMapping := map[string]string{}
+	err = yaml.UnmarshalStrict(mappingBytes, &orgMapping)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse organization mapping file: [%s]", mappingFile)
+	}
+
+	return orgMapping, nil
+}
+
+// orgName returns the name of the Pipeline organization to work in; an explicitly set name takes precedence over the
+// organization of the token, which takes precedence over the organization the repository owner is mapped to. The
+// repository owner is used if none of these is set.
+func (p *Plugin) orgName() string {
+	if p.Config.OrgName != "" {
+		return p.Config.OrgName
+	}
+
+	if p.tokenOrgName != "" {
+		return p.tokenOrgName
+	}
+
+	if orgName, ok := p.Config.OrgMapping[p.Repo.Owner]; ok {
+		log.Debugf("repository owner [%s] is mapped to organization [%s]", p.Repo.Owner, orgName)
+		return orgName
+	}
+
+	return p.Repo.Owner
+}
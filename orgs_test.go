@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOrgMapping(t *testing.T) {
+	workspace, err := ioutil.TempDir("", "workspace")
+	assert.Nil(t, err)
+	defer os.RemoveAll(workspace)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(workspace, "orgs.yml"), []byte("fork-owner: org1\nmirror-owner: org2\n"), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(workspace, "orgs.json"), []byte(`{"fork-owner": "org1"}`), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(workspace, "invalid.yml"), []byte("fork-owner: [org1]"), 0600))
+
+	tests := []struct {
+		name        string
+		mappingFile string
+		orgMapping  map[string]string
+		err         bool
+	}{
+		{
+			name: "no mapping file",
+		},
+		{
+			name:        "YAML mapping file",
+			mappingFile: "orgs.yml",
+			orgMapping:  map[string]string{"fork-owner": "org1", "mirror-owner": "org2"},
+		},
+		{
+			name:        "JSON mapping file",
+			mappingFile: filepath.Join(workspace, "orgs.json"),
+			orgMapping:  map[string]string{"fork-owner": "org1"},
+		},
+		{
+			name:        "missing mapping file",
+			mappingFile: "missing.yml",
+			err:         true,
+		},
+		{
+			name:        "invalid mapping file",
+			mappingFile: "invalid.yml",
+			err:         true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			orgMapping, err := readOrgMapping(test.mappingFile, workspace)
+			if test.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.orgMapping, orgMapping)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,6 +14,9 @@ import (
 
 	. "github.com/banzaicloud/banzai-types/components"
 	"github.com/banzaicloud/banzai-types/components/helm"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/banzaicloud/drone-plugin-pipeline-client/client"
+	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/go-playground/validator.v9"
@@ -66,72 +68,219 @@ type (
 		Commit  Commit
 		Config  Config
 		ApiCall ApiCaller
+
+		// the last cluster status observed while polling, used to log status transitions
+		observedClusterStatus string
+
+		// the organization the token was issued in, if the organization is taken from the token
+		tokenOrgName string
+
+		// the cluster status before the deletion was triggered, and whether the status changed since; a cluster already
+		// in ERROR state is expected to stay so till Pipeline starts deleting it
+		statusBeforeDeletion string
+		deletionStarted      bool
+
+		// the resources created in this run, cleaned up if the run is canceled
+		createdCluster     bool
+		createdSecret      bool
+		createdDeployments []*Deployment
 	}
 
 	Config struct {
 		Cluster     *CustomCluster
 		Deployment  *Deployment
+		Deployments []*Deployment
 		Endpoint    string
 		Token       string
+		Username    string
+		Password    string
 		OrgId       int
 		WaitTimeout int64
+
+		// OrgName the name of the organization to work in, the repository owner is used if not set
+		OrgName string
+
+		// OrgMapping maps repository owners to the organizations to work in
+		OrgMapping map[string]string
+
+		// OrgFromToken looks up the organization the token was issued in instead of the repository owner
+		OrgFromToken bool
+
+		// WaitForDeletion blocks the cluster deletion till the cluster is gone
+		WaitForDeletion bool
+
+		// ValidateCluster checks the cluster against the Pipeline cloud info before it's created or updated
+		ValidateCluster bool
+
+		// CleanupOnCancel deletes the resources created in this run if the run is canceled
+		CleanupOnCancel bool
+
+		// Retry the retry policy of the API calls failing with transient errors
+		Retry RetryPolicy
+
+		// Poll the intervals the resources are checked at while waiting for them
+		Poll PollPolicy
+
+		// Timeouts the time limits of the phases of the run, WaitTimeout is used for the unset ones
+		Timeouts Timeouts
+
+		// HttpClient the client the API calls are sent with, the default client is used if not set
+		HttpClient *http.Client
 	}
 
 	CustomCluster struct {
 		*CreateClusterRequest
 		State string
+
+		// KubeConfig the kubeconfig of the cluster to be imported in case of the kubernetes cloud, or the file holding it
+		// relative to the workspace
+		KubeConfig     string `json:"-"`
+		KubeConfigFile string `json:"-"`
 	}
 
 	Deployment struct {
 		Name        string                 `json:"name"`
 		ReleaseName string                 `json:"release_name"`
+		Version     string                 `json:"version"`
 		State       string                 `json:"state"`
 		ReuseValues bool                   `json:"reuse_values"`
 		Values      map[string]interface{} `json:"values"`
-	}
 
-	ConfigResponse struct {
-		Status int    `json:"status"`
-		Data   string `json:"data,omitempty"`
+		// ValuesFiles the values files merged in order before the values, relative to the workspace
+		ValuesFiles []string `json:"values_files"`
+
+		// DependsOn the release names of the deployments to be ready before this deployment is installed or upgraded
+		DependsOn []string `json:"depends_on"`
 	}
 
-	ApiCaller func(config *Config, url string, method string, body io.Reader) *http.Response
+	ApiCaller func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error)
 )
 
 const (
 	createdState = "created"
+	updatedState = "updated"
 	deletedState = "deleted"
 )
 
+// clusterUpdateGracePeriod the time the cluster may stay RUNNING after the update request before the update is
+// considered done; the first status checks might see the status from before the update
+const clusterUpdateGracePeriod = 30 * time.Second
+
 var validate *validator.Validate
 
-func (p *Plugin) Exec() error {
+// request assembles the Pipeline API request installing or updating the deployment
+func (d *Deployment) request() *helm.CreateUpdateDeploymentRequest {
+	return &helm.CreateUpdateDeploymentRequest{
+		Name:        d.Name,
+		ReleaseName: d.ReleaseName,
+		Version:     d.Version,
+		ReUseValues: d.ReuseValues,
+		Values:      d.Values,
+	}
+}
+
+// Exec runs the plugin till it's done, the context is canceled or the deadline of the run is exceeded; the resources
+// created in a canceled run are cleaned up if enabled
+func (p *Plugin) Exec(ctx context.Context) error {
+	runCtx := ctx
+	if p.Config.Timeouts.Deadline > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, p.Config.Timeouts.Deadline)
+		defer cancel()
+	}
+
+	err := p.exec(runCtx)
+	if err != nil && ctx.Err() == context.Canceled && p.Config.CleanupOnCancel {
+		p.cleanup()
+	}
+
+	return err
+}
+
+func (p *Plugin) exec(ctx context.Context) error {
 	log.Debug("start executing plugin logic ...")
 
-	resourceCreationTimeout := time.Duration(p.Config.WaitTimeout) * time.Second
+	timeouts := p.Config.Timeouts.orDefault(time.Duration(p.Config.WaitTimeout) * time.Second)
 
 	err := p.validate()
 	if err != nil {
 		return errors.Wrap(err, "validation error(s)")
 	}
 
-	_, err = p.GetOrgId()
+	err = p.checkToken()
+	if err != nil {
+		return errors.Wrap(err, "invalid token")
+	}
+
+	_, err = p.GetOrgId(ctx)
 	if err != nil {
 		return errors.Wrap(err, "could not retrieve organization id")
 	}
 
 	switch p.Config.Cluster.State {
-	case createdState:
-		if p.ClusterExists() {
+	case createdState, updatedState:
+		clusterExists, err := p.ClusterExists(ctx)
+		if err != nil {
+			return errors.Wrap(err, "could not check cluster existence")
+		}
+
+		if clusterExists && p.Config.Cluster.State == updatedState {
+			log.Infof("updating cluster [ %s ]", p.Config.Cluster.Name)
+			if err := p.resolveKubernetesVersions(ctx, false); err != nil {
+				return err
+			}
+
+			if err := p.checkClusterSpec(ctx); err != nil {
+				return err
+			}
+
+			err := p.updateCluster(ctx)
+			if err != nil {
+				return errors.Wrap(err, "cluster update failed")
+			}
+
+			err = p.waitForResource(ctx, fmt.Sprintf("cluster [%s] update", p.Config.Cluster.Name), timeouts.ClusterCreate,
+				p.clusterUpdated(time.Now(), clusterUpdateGracePeriod))
+			if err != nil {
+				log.Error("error while waiting for cluster update")
+				return errors.Wrap(err, "error while waiting for cluster update")
+			}
+
+			log.Infof("cluster [ %s ] updated.", p.Config.Cluster.Name)
+		} else if clusterExists {
 			log.Infof("reusing cluster [ %s ]", p.Config.Cluster.Name)
 		} else {
-			_, err := p.createCluster()
+			if err := p.resolveKubernetesVersions(ctx, true); err != nil {
+				return err
+			}
+
+			if p.Config.Cluster.Cloud == constants.Amazon {
+				if err := p.resolveAmazonImages(ctx); err != nil {
+					return err
+				}
+			}
+
+			if err := p.checkClusterSpec(ctx); err != nil {
+				return err
+			}
+
+			if p.Config.Cluster.Cloud == constants.Kubernetes && p.Config.Cluster.SecretId == "" {
+				err := p.createKubernetesSecret(ctx)
+				if err != nil {
+					return errors.Wrap(err, "kubernetes secret creation failed")
+				}
+			}
+
+			// the request might be accepted even if the run is canceled before the response arrives
+			p.createdCluster = true
+			_, err := p.createCluster(ctx)
 			if err != nil {
-				log.Fatalf("cluster creation failed: [ %s ]", err.Error())
+				log.Errorf("cluster creation failed: [ %s ]", err.Error())
 				return errors.Wrap(err, "cluster creation failed")
 			}
 
-			err = p.waitForResource(resourceCreationTimeout, p.ClusterExists)
+			err = p.waitForResource(ctx, fmt.Sprintf("cluster [%s] creation", p.Config.Cluster.Name), timeouts.ClusterCreate,
+				p.ClusterReady)
 			if err != nil {
 				log.Error("error while waiting for cluster creation")
 				return errors.Wrap(err, "error while waiting for cluster creation")
@@ -141,17 +290,47 @@ func (p *Plugin) Exec() error {
 		}
 
 		// we need the cluster config in order to interact with it
-		if !p.dumpClusterConfig() {
-			return errors.Errorf("could not dump configuration for cluster: [%s]", p.Config.Cluster.Name)
+		if err := p.dumpClusterConfig(ctx); err != nil {
+			return errors.Wrapf(err, "could not dump configuration for cluster: [%s]", p.Config.Cluster.Name)
 		}
 	case deletedState:
-		if p.ClusterExists() {
-			if p.deleteCluster() {
+		status, err := p.clusterStatus(ctx)
+		if err != nil {
+			return errors.Wrap(err, "could not check cluster existence")
+		}
+
+		if status != nil {
+			p.statusBeforeDeletion = status.Status
+			deleted, err := p.deleteCluster(ctx)
+			if err != nil {
+				return errors.Wrap(err, "cluster deletion failed")
+			}
+
+			if deleted {
 				log.Infof("triggered cluster deletion for: [ %s ].", p.Config.Cluster.Name)
+
+				// Pipeline needs the kubeconfig of an imported cluster till the cluster is deleted, its secret is only
+				// deleted afterwards
+				if p.Config.WaitForDeletion || p.Config.Cluster.Cloud == constants.Kubernetes {
+					err = p.waitForResource(ctx, fmt.Sprintf("cluster [%s] deletion", p.Config.Cluster.Name),
+						timeouts.ClusterDelete, p.ClusterDeleted)
+					if err != nil {
+						log.Error("error while waiting for cluster deletion")
+						return errors.Wrap(err, "error while waiting for cluster deletion")
+					}
+
+					log.Infof("cluster [ %s ] deleted.", p.Config.Cluster.Name)
+				}
 			}
 		} else {
 			log.Infof("cluster doesn't exist, nothing to delete: [ %s ].", p.Config.Cluster.Name)
 		}
+
+		if p.Config.Cluster.Cloud == constants.Kubernetes {
+			if err := p.deleteKubernetesSecret(ctx); err != nil {
+				return errors.Wrap(err, "kubernetes secret deletion failed")
+			}
+		}
 		// ending the flow here!
 		return nil
 	default:
@@ -159,48 +338,31 @@ func (p *Plugin) Exec() error {
 	}
 
 	log.Info("setting up helm ...")
-	err = p.waitForResource(resourceCreationTimeout, p.isHelmReady)
+	err = p.waitForResource(ctx, "helm", timeouts.Helm, withStatus(p.isHelmReady, "helm unavailable"))
 	if err != nil {
 		log.Error("error while setting up helm")
 		return errors.Wrap(err, "error while setting up helm")
 	}
 	log.Info("helm is ready.")
 
-	if len(p.Config.Deployment.Name) > 0 {
-		log.Infof("checking deployment [%s]", p.Config.Deployment.Name)
-		if p.Config.Deployment.State == createdState && !p.DeploymentExists() {
-			p.installDeployment()
-
-			err = p.waitForResource(resourceCreationTimeout, p.DeploymentExists)
-			if err != nil {
-				log.Error("error while waiting for deployment creation")
-				return errors.Wrap(err, "error while waiting for deployment creation")
-			}
-			err = p.waitForResource(resourceCreationTimeout, p.DeploymentReady)
-			if err != nil {
-				log.Error("error while waiting for deployment loadbalancer to get ready")
-				return errors.Wrap(err, "error while waiting for deployment loadbalancer to get ready")
-			}
-
-		} else if p.Config.Deployment.State == createdState {
-			log.Infof("deployment [%s] already exists, updating ...", p.Config.Deployment.Name)
-			p.updateDeployment()
+	if deployments := p.Config.deployments(); len(deployments) > 0 {
+		return p.runDeployments(ctx, deployments, timeouts)
+	}
 
-			err = p.waitForResource(resourceCreationTimeout, p.DeploymentExists)
-			if err != nil {
-				log.Error("error while waiting for deployment update")
-				return errors.Wrap(err, "error while waiting for deployment update")
-			}
+	return nil
+}
 
-			err = p.waitForResource(resourceCreationTimeout, p.DeploymentReady)
-			if err != nil {
-				log.Error("error while waiting for deployment loadbalancer to get ready")
-				return errors.Wrap(err, "error while waiting for deployment loadbalancer to get ready")
-			}
+// checkClusterSpec validates the cluster against the Pipeline cloud info if enabled
+func (p *Plugin) checkClusterSpec(ctx context.Context) error {
+	if !p.Config.ValidateCluster {
+		return nil
+	}
 
-		} else if p.Config.Deployment.State == deletedState && p.DeploymentExists() {
-			p.deleteDeployment()
-		}
+	log.Infof("validating cluster [ %s ]", p.Config.Cluster.Name)
+	err := p.validateClusterSpec(ctx)
+	if err != nil {
+		log.Errorf("invalid cluster [ %s ]: %s", p.Config.Cluster.Name, err.Error())
+		return errors.Wrap(err, "invalid cluster")
 	}
 
 	return nil
@@ -219,411 +381,520 @@ func (config *Config) requestAuth(request *http.Request) error {
 		return nil
 	}
 
-	log.Info("no credentials provided, no Authorization header is set ")
-	return nil
+	if len(config.Username) > 0 || len(config.Password) > 0 {
+		if len(config.Username) == 0 || len(config.Password) == 0 {
+			return errors.New("both username and password are required for basic authentication")
+		}
+
+		log.Debug("username and password provided, setting the basic Authorization header")
+		request.SetBasicAuth(config.Username, config.Password)
+		return nil
+	}
+
+	return errors.New("no credentials provided, either a token or a username and password are required")
 }
 
-func ApiCall(config *Config, url string, method string, body io.Reader) *http.Response {
+// ApiCall sends the request to the Pipeline API; idempotent requests failing with network errors or transient error
+// responses are retried according to the retry policy of the configuration till the context is done
+func ApiCall(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
 	log.Debugf("api call args -> url: [%s], method: [%s]", url, method)
 
+	// the body is buffered, so that it can be sent again
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		bodyBytes, err = ioutil.ReadAll(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read request body")
+		}
+	}
+
+	retries := config.Retry.newBackoff()
+	for {
+		resp, err := apiCallOnce(ctx, config, url, method, bodyBytes)
+		if !isIdempotent(method) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var retryAfter time.Duration
+		if err != nil {
+			if transient, _ := isTransient(err); !transient {
+				return nil, err
+			}
+		} else {
+			if !isTransientStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			retryAfter = client.RetryAfter(resp)
+		}
+
+		delay, ok := retries.next(retryAfter)
+		if !ok {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Warnf("retrying [%s] on [%s] in %s: [%s]", method, url, delay, err.Error())
+		} else {
+			log.Warnf("retrying [%s] on [%s] in %s: [%s]", method, url, delay, resp.Status)
+			resp.Body.Close()
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, errors.Wrapf(err, "failed to call [%s] on [%s]", method, url)
+		}
+	}
+}
+
+// apiCallOnce sends the request to the Pipeline API once
+func apiCallOnce(ctx context.Context, config *Config, url string, method string, bodyBytes []byte) (*http.Response, error) {
+	var body io.Reader
+	if bodyBytes != nil {
+		body = bytes.NewReader(bodyBytes)
+	}
+
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
-		log.Fatalf("could not create request [%s]", err.Error())
+		return nil, errors.Wrap(err, "could not create request")
 	}
+	req = req.WithContext(ctx)
 
 	err = config.requestAuth(req)
 	if err != nil {
-		log.Fatalf("failed to decorate request, error: [%s]", err.Error())
+		return nil, errors.Wrap(err, "failed to decorate request")
 	}
 
 	if method == http.MethodPost {
 		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	}
 
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := config.httpClient().Do(req)
 	if err != nil {
-		log.Fatalf("failed to build http request: [%s]", err.Error())
+		return nil, errors.Wrapf(err, "failed to call [%s] on [%s]", method, url)
 	}
 
-	req.Header.Add("Accept", "application/json")
+	return resp, nil
+}
+
+// pipelineClient returns the Pipeline API client sending its requests through the ApiCaller of the plugin
+func (p *Plugin) pipelineClient() *client.Client {
+	return client.New(p.Config.Endpoint, client.WithDoer(client.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return p.ApiCall(req.Context(), &p.Config, req.URL.String(), req.Method, req.Body)
+	})))
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// deleteCluster triggers the deletion of the cluster, false is returned if the cluster is not found
+func (p *Plugin) deleteCluster(ctx context.Context) (bool, error) {
+	log.Infof("initiating delete for cluster [ %s ]", p.Config.Cluster.Name)
 
+	deleteResponse, err := p.pipelineClient().DeleteCluster(ctx, p.Config.OrgId, p.Config.Cluster.Name)
+	if client.IsNotFound(err) {
+		log.Infof("cluster [%s] not found", p.Config.Cluster.Name)
+		return false, nil
+	}
 	if err != nil {
-		log.Fatalf("failed to call [%s] on [%s] , error: [%s]", method, url, err.Error())
+		return false, err
 	}
 
-	return resp
+	log.Infof("cluster [%s] is being deleted, id: [%d], message: [%s]", p.Config.Cluster.Name, deleteResponse.ResourceID,
+		deleteResponse.Message)
+	return true, nil
 }
 
-func (p *Plugin) deleteCluster() bool {
-	log.Infof("initiating delete for cluster [ %s ]", p.Config.Cluster.Name)
+func (p *Plugin) createCluster(ctx context.Context) (bool, error) {
+	log.Infof("creating cluster with name: [%s]", p.Config.Cluster.Name)
+
+	err := p.Config.Cluster.Validate()
+	if err != nil {
+		log.Errorf("invalid cluster creation request: [%s]", err.Error())
+		return false, errors.Wrap(err, "invalid cluster creation request")
+	}
 
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s?field=name", p.Config.Endpoint, p.Config.OrgId, p.Config.Cluster.Name)
-	resp := p.ApiCall(&p.Config, url, http.MethodDelete, nil)
-	defer resp.Body.Close()
+	create := func(ctx context.Context) error {
+		createResponse, err := p.pipelineClient().CreateCluster(ctx, p.Config.OrgId,
+			p.Config.Cluster.CreateClusterRequest)
+		if err != nil {
+			return err
+		}
 
-	if resp.StatusCode == http.StatusAccepted {
-		log.Infof("cluster [%s] is being deleted", p.Config.Cluster.Name)
-		return true
+		log.Infof("cluster creation request for [%s] has been accepted, id: [%d]", p.Config.Cluster.Name, createResponse.ResourceID)
+		return nil
 	}
 
-	if resp.StatusCode == http.StatusNotFound {
-		log.Infof("cluster [%s] not found", p.Config.Cluster.Name)
-		return false
+	// the cluster is found by HEAD only once it's available, its status tells whether it's being created already
+	exists := func(ctx context.Context) (bool, error) {
+		status, err := p.clusterStatus(ctx)
+		return status != nil, err
 	}
 
-	log.Fatalf("error while deleting cluster %+v", resp)
-	return false
-}
+	err = p.createWithRetry(ctx, fmt.Sprintf("cluster [%s]", p.Config.Cluster.Name), create, exists)
+	if err != nil {
+		return false, err
+	}
 
-func (p *Plugin) createCluster() (bool, error) {
-	log.Infof("creating cluster with name: [%s]", p.Config.Cluster.Name)
+	return true, nil
+}
 
-	url := fmt.Sprintf("%s/orgs/%d/clusters", p.Config.Endpoint, p.Config.OrgId)
-	param, err := json.Marshal(p.Config.Cluster)
+func (p *Plugin) updateCluster(ctx context.Context) error {
+	updateRequest := newUpdateClusterRequest(p.Config.Cluster.CreateClusterRequest)
+	err := updateRequest.Validate()
+	if err != nil {
+		log.Errorf("invalid cluster update request: [%s]", err.Error())
+		return errors.Wrap(err, "invalid cluster update request")
+	}
+	log.Debugf("update cluster request: [%s]", updateRequest.String())
 
+	err = p.pipelineClient().UpdateCluster(ctx, p.Config.OrgId, p.Config.Cluster.Name, updateRequest)
 	if err != nil {
-		log.Errorf("could not process cluster details. err: [%s]", err.Error())
-		return false, err
+		return err
 	}
 
-	resp := p.ApiCall(&p.Config, url, http.MethodPost, bytes.NewBuffer(param))
-	defer resp.Body.Close()
+	log.Infof("cluster update request for [%s] has been accepted", p.Config.Cluster.Name)
+	return nil
+}
 
-	switch resp.StatusCode {
-	case http.StatusOK: // 200
-		log.Infof("cluster [%s] is being created", p.Config.Cluster.Name)
-		return true, nil
-	case http.StatusAccepted: // 202
-		log.Infof("cluster creation request for [%s] has been accepted", p.Config.Cluster.Name)
-		return true, nil
-	case http.StatusBadRequest: // 400
-		return false, errors.New(fmt.Sprintf("bad request while creating cluster [%s]", resp.Status))
-	default:
-		return false, errors.New(fmt.Sprintf("unexpected response status code: [ %d ]", resp.StatusCode))
+// newUpdateClusterRequest assembles the update request for the cloud of the given create request; only the properties
+// the Pipeline API allows to be changed on a running cluster are carried over
+func newUpdateClusterRequest(createRequest *CreateClusterRequest) *UpdateClusterRequest {
+	updateRequest := &UpdateClusterRequest{
+		Cloud: createRequest.Cloud,
+	}
 
+	if provider, err := getClusterProvider(createRequest.Cloud); err == nil {
+		updateRequest.UpdateProperties = provider.updateProperties(createRequest)
 	}
 
+	return updateRequest
 }
 
-func (p *Plugin) isHelmReady() bool {
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s/deployments?field=name", p.Config.Endpoint, p.Config.OrgId, p.Config.Cluster.Name)
-	resp := p.ApiCall(&p.Config, url, http.MethodHead, nil)
-	defer resp.Body.Close()
-	log.Debugf("checking tiller. received response status code: [%d]", resp.StatusCode)
+func (p *Plugin) isHelmReady(ctx context.Context) (bool, error) {
+	ready, err := p.pipelineClient().HelmReady(ctx, p.Config.OrgId, p.Config.Cluster.Name)
+	if err != nil {
+		return false, err
+	}
 
-	switch resp.StatusCode {
-	case http.StatusOK:
+	if ready {
 		log.Debugf("helm is ready ...")
-		return true
-	case http.StatusServiceUnavailable:
-		log.Debugf("helm is unavailable...")
-		return false
-	case http.StatusBadRequest:
-		// todo fix the api to return the proper statuscode!
+	} else {
 		log.Debugf("helm is unavailable ...")
-		return false
-	default:
-		log.Debugf("(helm ready req) ignored status code: [%d]", resp.StatusCode)
 	}
-
-	log.Fatalf("Unexpected error %+v", resp)
-	return false
+	return ready, nil
 }
 
-func (p *Plugin) DeploymentExists() bool {
-
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s/deployments/%s?field=name", p.Config.Endpoint, p.Config.OrgId,
-		p.Config.Cluster.Name, p.Config.Deployment.ReleaseName)
-	resp := p.ApiCall(&p.Config, url, http.MethodHead, nil)
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK: //200
-		log.Debugf("deployment [%s] found", p.Config.Deployment.Name)
-		return true
-	case http.StatusNotFound: // 404
-		log.Debugf("deployment [%s] is not found", p.Config.Deployment.Name)
-		return false
-	case http.StatusNoContent: //204
-		log.Debugf("deployment [%s] is not yet ready", p.Config.Deployment.Name)
-		return false
-	default:
-		log.Debugf("(deployment exists req) ignored response status code [%d] ", resp.StatusCode)
+func (p *Plugin) DeploymentExists(ctx context.Context, d *Deployment) (bool, error) {
+	exists, err := p.pipelineClient().DeploymentExists(ctx, p.Config.OrgId, p.Config.Cluster.Name,
+		d.ReleaseName)
+	if err != nil {
+		return false, err
 	}
 
-	log.Fatalf("error while checking deployment %+v", resp)
-	return false
+	if exists {
+		log.Debugf("deployment [%s] found", d.Name)
+	} else {
+		log.Debugf("deployment [%s] is not found or not yet ready", d.Name)
+	}
+	return exists, nil
 }
 
-func (p *Plugin) DeploymentReady() bool {
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s/endpoints?field=name&releaseName=%s", p.Config.Endpoint, p.Config.OrgId,
-		p.Config.Cluster.Name, p.Config.Deployment.ReleaseName)
-	resp := p.ApiCall(&p.Config, url, http.MethodGet, nil)
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusAccepted: //202
-		log.Infof("Waiting for the loadbalancer to get ready for deployment %s", p.Config.Deployment.ReleaseName)
-		log.Debugf("deployment's [%s] loadbalancer is not ready", p.Config.Deployment.ReleaseName)
-		return false
-	case http.StatusOK: // 200
-		log.Debugf("deployment's [%s] loadbalancer is ready", p.Config.Deployment.ReleaseName)
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Debugf("could not read response body. error: [ %s ] ", err.Error())
+func (p *Plugin) DeploymentReady(ctx context.Context, d *Deployment) (bool, error) {
+	endpoints, err := p.pipelineClient().GetEndpoints(ctx, p.Config.OrgId, p.Config.Cluster.Name,
+		d.ReleaseName)
+	switch {
+	case client.IsStatus(err, http.StatusAccepted):
+		log.Infof("Waiting for the loadbalancer to get ready for deployment %s", d.ReleaseName)
+		log.Debugf("deployment's [%s] loadbalancer is not ready", d.ReleaseName)
+		return false, nil
+	case client.IsNotFound(err):
+		log.Debugf("Deployment does not have a public endpoint")
+		return true, nil
+	case err != nil:
+		return false, err
+	}
+
+	log.Debugf("deployment's [%s] loadbalancer is ready", d.ReleaseName)
+	log.Info("The available endpoints are the following:")
+	for _, endpoint := range endpoints.Endpoints {
+		if strings.Contains(endpoint.Name, d.ReleaseName) {
+			log.Info(endpoint.Host)
 		}
-		endpoints := helm.EndpointResponse{}
-		err = json.Unmarshal(bodyBytes, &endpoints)
-		log.Info("The available endpoints are the following:")
-		for _, endpoint := range endpoints.Endpoints {
-			if strings.Contains(endpoint.Name, p.Config.Deployment.ReleaseName) {
-				log.Info(endpoint.Host)
+		if endpoint.EndPointURLs != nil {
+			for _, url := range endpoint.EndPointURLs {
+				log.Info(url.URL)
 			}
-			if endpoint.EndPointURLs != nil {
-				for _, url := range endpoint.EndPointURLs {
-					log.Info(url.URL)
-				}
-			}
-		}
-		if err != nil {
-			log.Errorf("could not parse response: [ %s ]", err.Error())
 		}
-
-		return true
-	case http.StatusNotFound: //404
-		log.Debugf("Deployment does not have a public endpoint")
-		return true
-	default:
-		log.Debugf("(deployment exists req) ignored response status code [%d] ", resp.StatusCode)
 	}
 
-	log.Fatalf("error while checking deployment %+v", resp)
-	return false
+	return true, nil
 }
 
-func (p *Plugin) ClusterExists() bool {
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s?field=name", p.Config.Endpoint, p.Config.OrgId, p.Config.Cluster.Name)
-	resp := p.ApiCall(&p.Config, url, http.MethodHead, nil)
-	defer resp.Body.Close()
+func (p *Plugin) ClusterExists(ctx context.Context) (bool, error) {
+	exists, err := p.pipelineClient().ClusterExists(ctx, p.Config.OrgId, p.Config.Cluster.Name)
+	if err != nil {
+		return false, err
+	}
 
-	log.Debugf("response status code : [%d] ", resp.StatusCode)
-	switch resp.StatusCode {
-	case http.StatusOK:
+	if exists {
 		log.Debugf("cluster [%s] exists.", p.Config.Cluster.Name)
-		return true
-	case http.StatusNotFound:
-		log.Debugf("cluster [%s] not found.", p.Config.Cluster.Name)
-		return false
-	case http.StatusNoContent:
-		log.Debugf("cluster [%s] not yet alive.", p.Config.Cluster.Name)
-		return false
-	case http.StatusBadRequest:
-		log.Debugf("cluster [%s] not yet available.", p.Config.Cluster.Name)
-		return false
+	} else {
+		log.Debugf("cluster [%s] not found or not yet available.", p.Config.Cluster.Name)
+	}
+	return exists, nil
+}
 
-	default:
-		log.Debugf("(cluster status req) ignored response code : [%d] ", resp.StatusCode)
+// clusterStatus retrieves the status of the cluster from the Pipeline API, the returned status is nil if the cluster
+// is not found
+func (p *Plugin) clusterStatus(ctx context.Context) (*client.ClusterStatusResponse, error) {
+	status, err := p.pipelineClient().GetClusterStatus(ctx, p.Config.OrgId, p.Config.Cluster.Name)
+	if client.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	log.Fatalf("error while checking cluster existence. response code: [ %d ], status message: [ %s ]", resp.StatusCode, resp.Status)
-	return false
+	return status, nil
 }
 
-func (p *Plugin) dumpClusterConfig() bool {
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s/config?field=name", p.Config.Endpoint, p.Config.OrgId, p.Config.Cluster.Name)
-	resp := p.ApiCall(&p.Config, url, http.MethodGet, nil)
-	defer resp.Body.Close()
+// ClusterReady checks whether the cluster is in RUNNING state and reports the observed cluster status. Status
+// transitions are logged, an error is returned as soon as the cluster enters the ERROR state so that callers don't have
+// to wait for the timeout
+func (p *Plugin) ClusterReady(ctx context.Context) (bool, string, error) {
+	status, err := p.clusterStatus(ctx)
+	if err != nil {
+		if !keepPolling(ctx, err) {
+			return false, "", err
+		}
+		log.Debugf("cluster [%s] status not available: [%s]", p.Config.Cluster.Name, err.Error())
+		return false, "", nil
+	}
+
+	if status == nil {
+		log.Debugf("cluster [%s] not found.", p.Config.Cluster.Name)
+		return false, "not found", nil
+	}
 
-	result := ConfigResponse{}
+	p.observeClusterStatus(status)
 
-	if resp.StatusCode == http.StatusOK {
+	switch status.Status {
+	case constants.Running:
+		return true, status.Status, nil
+	case constants.Creating, constants.Updating, constants.Deleting:
+		return false, status.Status, nil
+	case constants.Error:
+		return false, status.Status, errors.Errorf("cluster [%s] is in %s state: [%s]", p.Config.Cluster.Name,
+			status.Status, status.StatusMessage)
+	default:
+		log.Debugf("(cluster status req) ignored cluster status: [%s]", status.Status)
+		return false, status.Status, nil
+	}
+}
 
-		err := json.NewDecoder(resp.Body).Decode(&result)
+// clusterUpdated checks whether the update of the cluster is done. The RUNNING state is only accepted once the cluster
+// left it or the grace period since the update request passed, as Pipeline might not have started the update yet
+func (p *Plugin) clusterUpdated(updateRequested time.Time, gracePeriod time.Duration) statusChecker {
+	updateStarted := false
 
-		if err != nil {
-			log.Fatalf("error while parsing JSON: [%s]", err.Error())
-			return false
+	return func(ctx context.Context) (bool, string, error) {
+		ready, status, err := p.ClusterReady(ctx)
+		if err != nil || status == "" {
+			return ready, status, err
 		}
 
-		wsConfigDir := path.Join(p.Build.Path, ".kube")
-		err = os.MkdirAll(wsConfigDir, 0755)
+		if status != constants.Running {
+			updateStarted = true
+		}
 
-		if err != nil {
-			log.Fatalf("unable to create dir: [%s], error: [%s]", wsConfigDir, err.Error())
-			return false
+		if ready && !updateStarted && time.Since(updateRequested) < gracePeriod {
+			log.Debugf("cluster [%s] is still %s, waiting for the update to start", p.Config.Cluster.Name, status)
+			return false, status, nil
 		}
 
-		wsConfigFile := path.Join(wsConfigDir, "config")
-		err = ioutil.WriteFile(wsConfigFile, []byte(result.Data), 0666)
+		return ready, status, nil
+	}
+}
 
-		if err != nil {
-			log.Fatalf("error while writing config file: [%s], error [%s]", wsConfigFile, err.Error())
-			return false
+// ClusterDeleted checks whether the cluster is gone and reports the observed cluster status. An error is returned if
+// the cluster enters the ERROR state once its status changed since the deletion was triggered; a failed cluster is
+// in ERROR state till Pipeline starts deleting it
+func (p *Plugin) ClusterDeleted(ctx context.Context) (bool, string, error) {
+	status, err := p.clusterStatus(ctx)
+	if err != nil {
+		if !keepPolling(ctx, err) {
+			return false, "", err
 		}
+		log.Debugf("cluster [%s] status not available: [%s]", p.Config.Cluster.Name, err.Error())
+		return false, "", nil
+	}
+
+	if status == nil {
+		log.Debugf("cluster [%s] not found.", p.Config.Cluster.Name)
+		return true, "not found", nil
+	}
 
-		log.Debugf("export KUBECONFIG=%s", wsConfigFile)
-		log.Infof("configuration written to workspace: [%s]", wsConfigFile)
+	p.observeClusterStatus(status)
 
-		return true
+	if status.Status != p.statusBeforeDeletion {
+		p.deletionStarted = true
 	}
 
-	log.Fatalf("error while dumping configuration: %+v", resp)
-	return false
+	if status.Status == constants.Error && p.deletionStarted {
+		return false, status.Status, errors.Errorf("cluster [%s] is in %s state: [%s]", p.Config.Cluster.Name,
+			status.Status, status.StatusMessage)
+	}
+
+	return false, status.Status, nil
+}
+
+// observeClusterStatus logs the cluster status if it changed since it was last observed
+func (p *Plugin) observeClusterStatus(status *client.ClusterStatusResponse) {
+	if status.Status != p.observedClusterStatus {
+		log.Infof("cluster [%s] status: [%s] %s", p.Config.Cluster.Name, status.Status, status.StatusMessage)
+		p.observedClusterStatus = status.Status
+	}
 }
 
-func (p *Plugin) installDeployment() bool {
+// dumpClusterConfig writes the kubeconfig of the cluster to the workspace
+func (p *Plugin) dumpClusterConfig(ctx context.Context) error {
+	result, err := p.pipelineClient().GetClusterConfig(ctx, p.Config.OrgId, p.Config.Cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	wsConfigDir := path.Join(p.Build.Path, ".kube")
+	err = os.MkdirAll(wsConfigDir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create dir: [%s]", wsConfigDir)
+	}
+
+	wsConfigFile := path.Join(wsConfigDir, "config")
+	err = ioutil.WriteFile(wsConfigFile, []byte(result.Data), 0666)
+	if err != nil {
+		return errors.Wrapf(err, "error while writing config file: [%s]", wsConfigFile)
+	}
+
+	log.Debugf("export KUBECONFIG=%s", wsConfigFile)
+	log.Infof("configuration written to workspace: [%s]", wsConfigFile)
+
+	return nil
+}
 
-	log.Infof("installing deployment [%s]", p.Config.Deployment.Name)
+// exportVariables writes the given variables to the env file in the workspace, so that later steps can source them
+func (p *Plugin) exportVariables(variables map[string]string) error {
+	if len(variables) == 0 {
+		return nil
+	}
 
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s/deployments?field=name", p.Config.Endpoint, p.Config.OrgId, p.Config.Cluster.Name)
-	param, _ := json.Marshal(p.Config.Deployment)
+	wsEnvDir := path.Join(p.Build.Path, ".pipeline")
+	err := os.MkdirAll(wsEnvDir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create dir: [%s]", wsEnvDir)
+	}
 
-	log.Debugf("install deployment request body: [%s]", param)
+	wsEnvFile := path.Join(wsEnvDir, "cluster.env")
+	exported, err := godotenv.Read(wsEnvFile)
+	if err != nil {
+		exported = map[string]string{}
+	}
 
-	resp := p.ApiCall(&p.Config, url, http.MethodPost, bytes.NewBuffer(param))
-	defer resp.Body.Close()
+	for name, value := range variables {
+		exported[name] = value
+		log.Debugf("export %s=%s", name, value)
+	}
 
-	if resp.StatusCode == http.StatusCreated {
-		log.Infof("deployment [%s] is being installed", p.Config.Deployment.Name)
-		return true
+	err = godotenv.Write(exported, wsEnvFile)
+	if err != nil {
+		return errors.Wrapf(err, "error while writing env file: [%s]", wsEnvFile)
 	}
 
-	log.Fatalf("error while installing deployment: %+v", resp)
-	return false
+	log.Infof("variables exported to workspace: [%s]", wsEnvFile)
+	return nil
 }
 
-func (p *Plugin) updateDeployment() bool {
+func (p *Plugin) installDeployment(ctx context.Context, d *Deployment) error {
 
-	log.Infof("updating deployment [%s]", p.Config.Deployment.Name)
+	log.Infof("installing deployment [%s], version: [%s]", d.Name, d.Version)
 
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s/deployments/%s?field=name", p.Config.Endpoint, p.Config.OrgId, p.Config.Cluster.Name, p.Config.Deployment.ReleaseName)
-	param, _ := json.Marshal(p.Config.Deployment)
+	request := d.request()
+	log.Debugf("install deployment request: [%+v]", request)
 
-	log.Debugf("updating deployment request body: [%s]", param)
-	resp := p.ApiCall(&p.Config, url, http.MethodPut, bytes.NewBuffer(param))
-	defer resp.Body.Close()
+	create := func(ctx context.Context) error {
+		_, err := p.pipelineClient().CreateDeployment(ctx, p.Config.OrgId, p.Config.Cluster.Name, request)
+		return err
+	}
+	exists := func(ctx context.Context) (bool, error) {
+		return p.DeploymentExists(ctx, d)
+	}
 
-	if resp.StatusCode == http.StatusCreated {
-		log.Infof("deployment [%s] is being updated", p.Config.Deployment.Name)
-		return true
+	err := p.createWithRetry(ctx, fmt.Sprintf("deployment [%s]", d.Name), create, exists)
+	if err != nil {
+		return err
 	}
 
-	log.Fatalf("error while updating deployment: %+v", resp)
-	return false
+	log.Infof("deployment [%s] is being installed", d.Name)
+	return nil
 }
 
-func (p *Plugin) deleteDeployment() bool {
+func (p *Plugin) updateDeployment(ctx context.Context, d *Deployment) error {
+
+	log.Infof("updating deployment [%s], version: [%s]", d.Name, d.Version)
+
+	request := d.request()
+	log.Debugf("updating deployment request: [%+v]", request)
 
-	log.Infof("initiating delete for deployment [%s]", p.Config.Deployment.Name)
+	_, err := p.pipelineClient().UpdateDeployment(ctx, p.Config.OrgId, p.Config.Cluster.Name, request)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("deployment [%s] is being updated", d.Name)
+	return nil
+}
 
-	url := fmt.Sprintf("%s/orgs/%d/clusters/%s/deployments/%s?field=name", p.Config.Endpoint, p.Config.OrgId,
-		p.Config.Cluster.Name, p.Config.Deployment.ReleaseName)
-	param, _ := json.Marshal(p.Config.Deployment)
+func (p *Plugin) deleteDeployment(ctx context.Context, d *Deployment) error {
 
-	resp := p.ApiCall(&p.Config, url, http.MethodDelete, bytes.NewBuffer(param))
-	defer resp.Body.Close()
+	log.Infof("initiating delete for deployment [%s]", d.Name)
 
-	if resp.StatusCode == http.StatusOK {
-		log.Infof("deployment [%s] is being deleted", p.Config.Deployment.Name)
-		return true
+	_, err := p.pipelineClient().DeleteDeployment(ctx, p.Config.OrgId, p.Config.Cluster.Name,
+		d.ReleaseName)
+	if err != nil {
+		return err
 	}
 
-	log.Fatalf("error while deleting deployment %+v", resp)
-	return false
+	log.Infof("deployment [%s] is being deleted", d.Name)
+	return nil
 }
 
 // GetOrgId retrieves the identifier of the GitHub organization and sets it into the plugin configuration for further reuse
-func (p *Plugin) GetOrgId() (int, error) {
+func (p *Plugin) GetOrgId(ctx context.Context) (int, error) {
 
 	if p.Config.OrgId != 0 {
 		log.Debugf("found cached org id: [ %d ]", p.Config.OrgId)
 		return p.Config.OrgId, nil
 	}
 
-	log.Debugf("looking up id for org: [ %s ]", p.Repo.Owner)
-	url := fmt.Sprintf("%s/orgs?field=name", p.Config.Endpoint)
-	httpResp := p.ApiCall(&p.Config, url, http.MethodGet, nil)
-	defer httpResp.Body.Close()
-
-	if httpResp.StatusCode != http.StatusOK {
-		log.Errorf("could not retrieve organizations. cause: [ %s ]", httpResp.Status)
-		return 0, errors.Errorf("could not retrieve organizations. status: [ %s ]", httpResp.Status)
-	}
-
-	var (
-		orgInfoList []struct {
-			Id   int    `json:"id"`
-			Name string `json:"name"`
-		}
-		bodyBytes []byte
-		err       error
-	)
-
-	bodyBytes, err = ioutil.ReadAll(httpResp.Body)
+	orgName := p.orgName()
+	log.Debugf("looking up id for org: [ %s ]", orgName)
+	organizations, err := p.pipelineClient().Organizations(ctx)
 	if err != nil {
-		log.Debugf("could not read response body. error: [ %s ] ", err.Error())
+		log.Errorf("could not retrieve organizations. cause: [ %s ]", err.Error())
 		return 0, err
 	}
 
-	// parsing the data we need from the response
-	log.Debugf("organizations response: [%s]", string(bodyBytes))
-	err = json.Unmarshal(bodyBytes, &orgInfoList)
-	if err != nil {
-		log.Errorf("could not parse orgs response: [ %s ]", err.Error())
-		return 0, errors.Wrapf(err, "could not parse orgs response [ %s ]", string(bodyBytes))
-	}
+	orgNames := make([]string, 0, len(organizations))
+	for _, orgInfo := range organizations {
 
-	for _, orgInfo := range orgInfoList {
-
-		if orgInfo.Name == p.Repo.Owner {
+		if orgInfo.Name == orgName {
 			log.Debugf("found org: [ %s ], with id: [ %d ]", orgInfo.Name, orgInfo.Id)
 			p.Config.OrgId = orgInfo.Id
 			return p.Config.OrgId, nil
 		}
+		orgNames = append(orgNames, orgInfo.Name)
 	}
 
-	log.Debugf("could not find organization: [%s]", p.Repo.Owner)
-	return 0, fmt.Errorf("could not find id for organization: [%s]" + p.Repo.Owner)
-
-}
-
-// waitForResource given a timeout period and a resource checker function this method blocks till the resource becomes available
-// or the timeout period is exceeded
-func (p *Plugin) waitForResource(timeout time.Duration, resourceChecker func() bool) error {
-	log.Info("checking for the resource availability ...")
-
-	// set up a context instance to control timeout and cancel waiting for resources
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// this channel is written when the resource becomes available
-	pollerChan := make(chan string)
-
-	poller := func() {
-
-		if resourceChecker() {
-			// only write in the channel in case the resource is available
-			pollerChan <- "ready"
-			close(pollerChan)
-			return
-		}
-		log.Debug("resource not yet available")
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Error("timeout happened")
-			return ctx.Err()
-		case <-pollerChan:
-			log.Debug("resource available")
-			return nil
-		default:
-			go poller()
-			time.Sleep(5 * time.Second)
-		}
-	}
+	log.Debugf("could not find organization: [%s]", orgName)
+	return 0, errors.Errorf("could not find id for organization: [%s], available organizations: %s", orgName, listOf(orgNames))
 
 }
 
@@ -7,11 +7,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 
 	"context"
 	"time"
 
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/amazon"
+	"github.com/banzaicloud/banzai-types/components/azure"
+	"github.com/banzaicloud/banzai-types/components/dummy"
+	"github.com/banzaicloud/banzai-types/components/google"
+	"github.com/banzaicloud/banzai-types/constants"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
@@ -27,6 +34,7 @@ func TestAuthorizationHeader(t *testing.T) {
 		name       string
 		config     Config
 		authHeader string
+		err        string
 	}{
 		{
 			name: "Bearer token auth",
@@ -35,18 +43,49 @@ func TestAuthorizationHeader(t *testing.T) {
 			},
 			authHeader: "Bearer bearertoken",
 		},
+		{
+			name: "Basic auth",
+			config: Config{
+				Username: "admin",
+				Password: "secret",
+			},
+			authHeader: "Basic YWRtaW46c2VjcmV0",
+		},
+		{
+			name: "Bearer token auth preferred",
+			config: Config{
+				Token:    "bearertoken",
+				Username: "admin",
+				Password: "secret",
+			},
+			authHeader: "Bearer bearertoken",
+		},
+		{
+			name: "Username without password",
+			config: Config{
+				Username: "admin",
+			},
+			authHeader: "",
+			err:        "both username and password are required for basic authentication",
+		},
 		{
 			name:       "No auth",
 			config:     Config{},
 			authHeader: "",
+			err:        "no credentials provided, either a token or a username and password are required",
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			request, _ := http.NewRequest(http.MethodPost, "", nil)
-			tc.config.requestAuth(request)
+			err := tc.config.requestAuth(request)
 			assert.Equal(t, tc.authHeader, request.Header.Get("Authorization"))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
 		})
 	}
 
@@ -73,27 +112,69 @@ func TestPlugin_GetOrgId(t *testing.T) {
 		{
 			name: "retrieving org id - no repo owner found",
 			plugin: Plugin{
-				ApiCall: func(config *Config, url string, method string, body io.Reader) *http.Response {
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
 					return &http.Response{
 						StatusCode: http.StatusOK,
 						Body:       ioutil.NopCloser(bytes.NewReader([]byte(orgsResponse))),
-					}
+					}, nil
 				},
 				Config: Config{},
 			},
 			assert: func(i int, err error) {
 				assert.Equal(t, 0, i, "the returned org id must be 0 (nil value)")
-				assert.NotNil(t, err, "the returned error must not be nil")
+				assert.EqualError(t, err, "could not find id for organization: [], available organizations: [org1, org2]")
+			},
+		},
+		{
+			name: "retrieving org id - org name set",
+			plugin: Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(orgsResponse))),
+					}, nil
+				},
+				Repo: Repo{
+					Owner: "org1",
+				},
+				Config: Config{
+					OrgName: "org2",
+				},
+			},
+			assert: func(i int, err error) {
+				assert.Equal(t, 2, i)
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "retrieving org id - repo owner mapped",
+			plugin: Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(orgsResponse))),
+					}, nil
+				},
+				Repo: Repo{
+					Owner: "fork-owner",
+				},
+				Config: Config{
+					OrgMapping: map[string]string{"fork-owner": "org2"},
+				},
+			},
+			assert: func(i int, err error) {
+				assert.Equal(t, 2, i)
+				assert.Nil(t, err)
 			},
 		},
 		{
 			name: "retrieving org id - repo owner found",
 			plugin: Plugin{
-				ApiCall: func(config *Config, url string, method string, body io.Reader) *http.Response {
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
 					return &http.Response{
 						StatusCode: http.StatusOK,
 						Body:       ioutil.NopCloser(bytes.NewReader([]byte(orgsResponse))),
-					}
+					}, nil
 				},
 				Repo: Repo{
 					Owner: "org1",
@@ -108,12 +189,12 @@ func TestPlugin_GetOrgId(t *testing.T) {
 		{
 			name: "retrieving org id - not OK response code",
 			plugin: Plugin{
-				ApiCall: func(config *Config, url string, method string, body io.Reader) *http.Response {
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
 					return &http.Response{
 						StatusCode: http.StatusBadRequest,
 						Status:     "200 OK",
 						Body:       ioutil.NopCloser(bytes.NewReader([]byte(orgsResponse))),
-					}
+					}, nil
 				},
 				Config: Config{},
 			},
@@ -125,12 +206,12 @@ func TestPlugin_GetOrgId(t *testing.T) {
 		{
 			name: "retrieving org id - invalid json payload",
 			plugin: Plugin{
-				ApiCall: func(config *Config, url string, method string, body io.Reader) *http.Response {
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
 
 					return &http.Response{
 						StatusCode: http.StatusOK,
 						Body:       ioutil.NopCloser(bytes.NewReader([]byte(invalidJsonResponse))),
-					}
+					}, nil
 				},
 				Config: Config{},
 			},
@@ -140,11 +221,24 @@ func TestPlugin_GetOrgId(t *testing.T) {
 					invalidJsonResponse, "i"), "Invalide error message")
 			},
 		},
+		{
+			name: "retrieving org id - api call failure",
+			plugin: Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					return nil, errors.New("connection refused")
+				},
+				Config: Config{},
+			},
+			assert: func(i int, err error) {
+				assert.Equal(t, 0, i, "the returned org id must have the nil value")
+				assert.EqualError(t, err, "connection refused")
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			test.assert(test.plugin.GetOrgId())
+			test.assert(test.plugin.GetOrgId(context.Background()))
 		})
 	}
 }
@@ -153,14 +247,15 @@ func TestPlugin_WaitForResource(t *testing.T) {
 	tests := []struct {
 		name            string //name of the test case
 		timeout         time.Duration
-		resourceChecker func() bool
+		canceled        bool
+		resourceChecker func(ctx context.Context) (bool, error)
 		assert          func(err error) // assertions
 	}{
 		{
 			name:    "resource is available",
 			timeout: 5 * time.Second,
-			resourceChecker: func() bool {
-				return true
+			resourceChecker: func(ctx context.Context) (bool, error) {
+				return true, nil
 			},
 			assert: func(err error) {
 				assert.Nil(t, err, "the result should not be nil")
@@ -169,11 +264,34 @@ func TestPlugin_WaitForResource(t *testing.T) {
 		{
 			name:    "resource is not available - timeout",
 			timeout: 5 * time.Second,
-			resourceChecker: func() bool {
-				return false
+			resourceChecker: func(ctx context.Context) (bool, error) {
+				return false, nil
+			},
+			assert: func(err error) {
+				assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+				assert.EqualError(t, err, "timed out waiting for test resource after 5s, last status: [not ready]")
+			},
+		},
+		{
+			name:    "resource check failed",
+			timeout: 5 * time.Minute,
+			resourceChecker: func(ctx context.Context) (bool, error) {
+				return false, errors.New("resource failed")
 			},
 			assert: func(err error) {
-				assert.EqualError(t, context.DeadlineExceeded, err.Error())
+				assert.EqualError(t, err, "resource failed")
+			},
+		},
+		{
+			name:     "waiting canceled",
+			timeout:  5 * time.Minute,
+			canceled: true,
+			resourceChecker: func(ctx context.Context) (bool, error) {
+				return false, nil
+			},
+			assert: func(err error) {
+				assert.Equal(t, context.Canceled, errors.Cause(err))
+				assert.EqualError(t, err, "canceled waiting for test resource after 0s, last status: [not ready]")
 			},
 		},
 	}
@@ -181,8 +299,412 @@ func TestPlugin_WaitForResource(t *testing.T) {
 	p := Plugin{}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			test.assert(p.waitForResource(test.timeout, test.resourceChecker))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.canceled {
+				cancel()
+			}
+
+			test.assert(p.waitForResource(ctx, "test resource", test.timeout, withStatus(test.resourceChecker, "not ready")))
+		})
+	}
+
+}
+
+func TestNewUpdateClusterRequest(t *testing.T) {
+	createRequest := &components.CreateClusterRequest{
+		Name:  "test-cluster",
+		Cloud: constants.Amazon,
+	}
+	createRequest.Properties.CreateClusterAmazon = &amazon.CreateClusterAmazon{
+		NodePools: map[string]*amazon.AmazonNodePool{
+			"pool1": {
+				InstanceType: "m4.xlarge",
+				MinCount:     2,
+				MaxCount:     5,
+			},
+		},
+	}
+	createRequest.Properties.CreateClusterAzure = &azure.CreateClusterAzure{}
+
+	updateRequest := newUpdateClusterRequest(createRequest)
+
+	assert.Equal(t, constants.Amazon, updateRequest.Cloud)
+	assert.Nil(t, updateRequest.Azure, "properties of other clouds must not be sent")
+	assert.Equal(t, &amazon.UpdateAmazonNodePool{MinCount: 2, MaxCount: 5}, updateRequest.Amazon.NodePools["pool1"])
+	assert.Nil(t, updateRequest.Validate())
+
+	createRequest = &components.CreateClusterRequest{
+		Name:  "test-cluster",
+		Cloud: constants.Dummy,
+	}
+	createRequest.Properties.CreateClusterDummy = &dummy.CreateClusterDummy{
+		Node: &dummy.Node{KubernetesVersion: "1.10.0", Count: 3},
+	}
+
+	updateRequest = newUpdateClusterRequest(createRequest)
+
+	assert.Equal(t, constants.Dummy, updateRequest.Cloud)
+	assert.Nil(t, updateRequest.Amazon, "properties of other clouds must not be sent")
+	assert.Equal(t, &dummy.Node{KubernetesVersion: "1.10.0", Count: 3}, updateRequest.Dummy.Node)
+	assert.Nil(t, updateRequest.Validate())
+
+	createRequest = &components.CreateClusterRequest{
+		Name:  "test-cluster",
+		Cloud: constants.Google,
+	}
+	createRequest.Properties.CreateClusterGoogle = &google.CreateClusterGoogle{
+		Project: "test-project",
+		Master:  &google.Master{},
+		NodePools: map[string]*google.NodePool{
+			"pool1": {Count: 3, NodeInstanceType: "n1-standard-4", ServiceAccount: "test-account"},
+		},
+	}
+
+	updateRequest = newUpdateClusterRequest(createRequest)
+
+	assert.Equal(t, constants.Google, updateRequest.Cloud)
+	assert.Equal(t, &google.UpdateClusterGoogle{
+		NodePools: map[string]*google.NodePool{"pool1": {Count: 3}},
+	}, updateRequest.Google, "only the node pool counts must be sent")
+	assert.Nil(t, updateRequest.Validate())
+}
+
+func TestPlugin_CreateCluster(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		statusBody string
+		posts      int
+	}{
+		{
+			name:       "cluster being created despite the failed request",
+			statusCode: http.StatusOK,
+			statusBody: `{"status":"CREATING","name":"test-cluster"}`,
+			posts:      1,
+		},
+		{
+			name:       "cluster not found after the failed request",
+			statusCode: http.StatusNotFound,
+			posts:      2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			posts := 0
+			createRequest := &components.CreateClusterRequest{Name: "test-cluster", Location: "eu-west-1", Cloud: constants.Dummy}
+			createRequest.Properties.CreateClusterDummy = &dummy.CreateClusterDummy{}
+
+			p := Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, reqBody io.Reader) (*http.Response, error) {
+					statusCode, body := test.statusCode, test.statusBody
+					switch method {
+					case http.MethodHead:
+						// the cluster being created is not yet available
+						statusCode, body = http.StatusNoContent, ``
+					case http.MethodPost:
+						posts++
+						statusCode, body = http.StatusAccepted, `{"id":1}`
+						if posts == 1 {
+							statusCode, body = http.StatusBadGateway, ``
+						}
+					}
+					return &http.Response{
+						StatusCode: statusCode,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+					}, nil
+				},
+				Config: Config{
+					OrgId:   1,
+					Cluster: &CustomCluster{CreateClusterRequest: createRequest},
+					Retry:   RetryPolicy{MaxAttempts: 3, Budget: time.Minute},
+				},
+			}
+
+			created, err := p.createCluster(context.Background())
+			assert.Nil(t, err)
+			assert.True(t, created)
+			assert.Equal(t, test.posts, posts)
+		})
+	}
+}
+
+func TestPlugin_ClusterReady(t *testing.T) {
+	statusResponse := func(statusCode int, body string) ApiCaller {
+		return func(ctx context.Context, config *Config, url string, method string, reqBody io.Reader) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			}, nil
+		}
+	}
+
+	tests := []struct {
+		name    string
+		apiCall ApiCaller
+		ready   bool
+		err     string
+	}{
+		{
+			name:    "cluster is running",
+			apiCall: statusResponse(http.StatusOK, `{"status":"RUNNING","name":"test-cluster"}`),
+			ready:   true,
+		},
+		{
+			name:    "cluster is being created",
+			apiCall: statusResponse(http.StatusOK, `{"status":"CREATING","name":"test-cluster"}`),
+		},
+		{
+			name:    "cluster not found",
+			apiCall: statusResponse(http.StatusNotFound, ``),
+		},
+		{
+			name:    "cluster is in error state",
+			apiCall: statusResponse(http.StatusOK, `{"status":"ERROR","statusMessage":"insufficient quota","name":"test-cluster"}`),
+			err:     "cluster [test-cluster] is in ERROR state: [insufficient quota]",
+		},
+		{
+			name:    "pipeline unavailable",
+			apiCall: statusResponse(http.StatusServiceUnavailable, ``),
+		},
+		{
+			name:    "unauthorized",
+			apiCall: statusResponse(http.StatusUnauthorized, `{"message":"invalid token"}`),
+			err:     "could not retrieve cluster status. status: [ 401 Unauthorized ], message: [ invalid token ]",
+		},
+		{
+			name: "network error",
+			apiCall: func(ctx context.Context, config *Config, url string, method string, reqBody io.Reader) (*http.Response, error) {
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Plugin{
+				ApiCall: test.apiCall,
+				Config: Config{
+					Cluster: &CustomCluster{
+						CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"},
+					},
+				},
+			}
+
+			ready, _, err := p.ClusterReady(context.Background())
+			assert.Equal(t, test.ready, ready)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestPlugin_ClusterUpdated(t *testing.T) {
+	tests := []struct {
+		name            string
+		updateRequested time.Time
+		statuses        []string
+		ready           []bool
+	}{
+		{
+			name:            "update started",
+			updateRequested: time.Now(),
+			statuses:        []string{"RUNNING", "UPDATING", "RUNNING"},
+			ready:           []bool{false, false, true},
+		},
+		{
+			name:            "grace period passed",
+			updateRequested: time.Now().Add(-time.Minute),
+			statuses:        []string{"RUNNING"},
+			ready:           []bool{true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			polls := 0
+			p := Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, reqBody io.Reader) (*http.Response, error) {
+					body := fmt.Sprintf(`{"status":"%s","name":"test-cluster"}`, test.statuses[polls])
+					polls++
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+					}, nil
+				},
+				Config: Config{
+					Cluster: &CustomCluster{
+						CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"},
+					},
+				},
+			}
+
+			clusterUpdated := p.clusterUpdated(test.updateRequested, 30*time.Second)
+			for i, expected := range test.ready {
+				ready, status, err := clusterUpdated(context.Background())
+				assert.Nil(t, err)
+				assert.Equal(t, test.statuses[i], status)
+				assert.Equal(t, expected, ready, "poll %d", i+1)
+			}
 		})
 	}
+}
 
+func TestPlugin_ClusterDeleted(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		deleted    bool
+		err        string
+	}{
+		{
+			name:       "cluster is being deleted",
+			statusCode: http.StatusOK,
+			body:       `{"status":"DELETING","name":"test-cluster"}`,
+		},
+		{
+			name:       "cluster is gone",
+			statusCode: http.StatusNotFound,
+			deleted:    true,
+		},
+		{
+			name:       "cluster deletion failed",
+			statusCode: http.StatusOK,
+			body:       `{"status":"ERROR","statusMessage":"dependency violation","name":"test-cluster"}`,
+			err:        "cluster [test-cluster] is in ERROR state: [dependency violation]",
+		},
+		{
+			name:       "pipeline unavailable",
+			statusCode: http.StatusBadGateway,
+		},
+		{
+			name:       "internal server error",
+			statusCode: http.StatusInternalServerError,
+			err:        "could not retrieve cluster status. status: [ 500 Internal Server Error ]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, reqBody io.Reader) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: test.statusCode,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(test.body))),
+					}, nil
+				},
+				Config: Config{
+					Cluster: &CustomCluster{
+						CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"},
+					},
+				},
+			}
+
+			deleted, _, err := p.ClusterDeleted(context.Background())
+			assert.Equal(t, test.deleted, deleted)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestPlugin_ClusterDeleted_FailedCluster(t *testing.T) {
+	statuses := []string{"ERROR", "DELETING", "ERROR"}
+	polls := 0
+
+	p := Plugin{
+		ApiCall: func(ctx context.Context, config *Config, url string, method string, reqBody io.Reader) (*http.Response, error) {
+			body := fmt.Sprintf(`{"status":"%s","statusMessage":"dependency violation","name":"test-cluster"}`, statuses[polls])
+			polls++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			}, nil
+		},
+		Config: Config{
+			Cluster: &CustomCluster{
+				CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"},
+			},
+		},
+		statusBeforeDeletion: "ERROR",
+	}
+
+	// the cluster failed before the deletion was triggered
+	_, status, err := p.ClusterDeleted(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "ERROR", status)
+
+	_, status, err = p.ClusterDeleted(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETING", status)
+
+	_, _, err = p.ClusterDeleted(context.Background())
+	assert.EqualError(t, err, "cluster [test-cluster] is in ERROR state: [dependency violation]")
+}
+
+func TestPlugin_ClusterExists(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		callErr    error
+		exists     bool
+		err        string
+	}{
+		{
+			name:       "cluster exists",
+			statusCode: http.StatusOK,
+			exists:     true,
+		},
+		{
+			name:       "cluster not found",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "unexpected response",
+			statusCode: http.StatusInternalServerError,
+			err:        "could not check cluster existence. status: [ 500 Internal Server Error ]",
+		},
+		{
+			name:    "api call failure",
+			callErr: errors.New("connection refused"),
+			err:     "connection refused",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, reqBody io.Reader) (*http.Response, error) {
+					if test.callErr != nil {
+						return nil, test.callErr
+					}
+					return &http.Response{
+						StatusCode: test.statusCode,
+						Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+					}, nil
+				},
+				Config: Config{
+					Cluster: &CustomCluster{
+						CreateClusterRequest: &components.CreateClusterRequest{Name: "test-cluster"},
+					},
+				},
+			}
+
+			exists, err := p.ClusterExists(context.Background())
+			assert.Equal(t, test.exists, exists)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	// pollInterval the default delay before the resource is checked again
+	pollInterval = 5 * time.Second
+	// pollBackoff the default factor the delay is multiplied by after every check
+	pollBackoff = 1.5
+	// pollMaxInterval the default upper limit of the delay between two checks
+	pollMaxInterval = 30 * time.Second
+)
+
+type (
+	// PollPolicy the intervals the resources are checked at while waiting for them, the defaults are used for the
+	// unset fields
+	PollPolicy struct {
+		Interval    time.Duration
+		Backoff     float64
+		MaxInterval time.Duration
+	}
+
+	// Timeouts the time limits of waiting for the resources in the phases of the run, the resource timeout is used for
+	// the unset phases
+	Timeouts struct {
+		// ClusterCreate the time limit of the cluster creation and update
+		ClusterCreate time.Duration
+		ClusterDelete time.Duration
+		Helm          time.Duration
+		// Release the time limit of installing or upgrading a release
+		Release  time.Duration
+		Endpoint time.Duration
+
+		// Deadline the time limit of the whole run, not limited if not set
+		Deadline time.Duration
+	}
+
+	// waitError reports that waiting for a resource ended before the resource became available
+	waitError struct {
+		phase      string
+		elapsed    time.Duration
+		lastStatus string
+		// deadline whether the deadline of the whole run was exceeded rather than the time limit of the phase
+		deadline bool
+		cause    error
+	}
+
+	// statusChecker checks whether the resource is ready, the observed status of the resource is reported for the
+	// progress logs; an empty status means the status is not known
+	statusChecker func(ctx context.Context) (ready bool, status string, err error)
+)
+
+// orDefault returns the timeouts with the unset phase time limits set to the given default
+func (timeouts Timeouts) orDefault(defaultTimeout time.Duration) Timeouts {
+	for _, timeout := range []*time.Duration{&timeouts.ClusterCreate, &timeouts.ClusterDelete, &timeouts.Helm,
+		&timeouts.Release, &timeouts.Endpoint} {
+		if *timeout <= 0 {
+			*timeout = defaultTimeout
+		}
+	}
+	return timeouts
+}
+
+func (e *waitError) Error() string {
+	var reason string
+	switch {
+	case e.cause == context.Canceled:
+		reason = "canceled"
+	case e.deadline:
+		reason = "step deadline exceeded"
+	default:
+		reason = "timed out"
+	}
+	return fmt.Sprintf("%s waiting for %s after %s, last status: [%s]", reason, e.phase, e.elapsed, e.lastStatus)
+}
+
+// Cause the error of the context waiting ended with
+func (e *waitError) Cause() error {
+	return e.cause
+}
+
+// newPollPolicy assembles the poll policy from the plugin settings, the settings that would make the checks more
+// frequent over time are rejected
+func newPollPolicy(c *cli.Context) (PollPolicy, error) {
+	policy := PollPolicy{
+		Interval:    time.Duration(c.Int64("plugin.poll.interval")) * time.Second,
+		Backoff:     c.Float64("plugin.poll.backoff"),
+		MaxInterval: time.Duration(c.Int64("plugin.poll.max_interval")) * time.Second,
+	}
+
+	if policy.Interval < 0 {
+		return PollPolicy{}, errors.Errorf("plugin.poll.interval must not be negative: [%d]", c.Int64("plugin.poll.interval"))
+	}
+	if policy.Backoff < 1 {
+		return PollPolicy{}, errors.Errorf("plugin.poll.backoff must be at least 1: [%g]", policy.Backoff)
+	}
+	if policy.MaxInterval < 0 {
+		return PollPolicy{}, errors.Errorf("plugin.poll.max_interval must not be negative: [%d]",
+			c.Int64("plugin.poll.max_interval"))
+	}
+
+	return policy, nil
+}
+
+// first the delay before the second check
+func (policy PollPolicy) first() time.Duration {
+	if policy.Interval > 0 {
+		return policy.Interval
+	}
+	return pollInterval
+}
+
+// next the delay following the given one
+func (policy PollPolicy) next(interval time.Duration) time.Duration {
+	backoff := policy.Backoff
+	if backoff == 0 {
+		backoff = pollBackoff
+	}
+
+	maxInterval := policy.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = pollMaxInterval
+	}
+
+	next := time.Duration(float64(interval) * backoff)
+	if next > maxInterval {
+		next = maxInterval
+	}
+	if next < interval {
+		// the max interval is lower than the first interval
+		return interval
+	}
+	return next
+}
+
+// waitForResource given a timeout period and a resource checker function this method blocks till the resource becomes available,
+// the timeout period is exceeded, the resource checker reports an error or the context is done. The resource is
+// checked sequentially at the intervals of the poll policy; the phase names what is waited for in the logs and errors.
+func (p *Plugin) waitForResource(parent context.Context, phase string, timeout time.Duration, resourceChecker statusChecker) error {
+	log.Infof("waiting for %s ...", phase)
+
+	// set up a context instance to control timeout and cancel waiting for resources
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	start := time.Now()
+	interval := p.Config.Poll.first()
+	lastStatus := "unknown"
+
+	// waitEnded reports why waiting for the resource ended before it became available
+	waitEnded := func(err error) error {
+		waitErr := &waitError{
+			phase:      phase,
+			elapsed:    time.Since(start).Round(time.Second),
+			lastStatus: lastStatus,
+			deadline:   parent.Err() == context.DeadlineExceeded,
+			cause:      err,
+		}
+		if err == context.Canceled {
+			log.Warn(waitErr.Error())
+		} else {
+			log.Error(waitErr.Error())
+		}
+		return waitErr
+	}
+
+	for {
+		ready, status, err := resourceChecker(ctx)
+		if status != "" {
+			lastStatus = status
+		}
+
+		elapsed := time.Since(start).Round(time.Second)
+		switch {
+		case ready && err == nil:
+			log.Debugf("%s done after %s", phase, elapsed)
+			return nil
+		case ctx.Err() != nil:
+			// the check was interrupted
+			return waitEnded(ctx.Err())
+		case err != nil:
+			log.Errorf("%s failed after %s: [%s]", phase, elapsed, err.Error())
+			return err
+		}
+
+		log.Infof("waiting for %s for %s, last status: [%s], checking again in %s", phase, elapsed, lastStatus,
+			interval)
+
+		if err := sleep(ctx, interval); err != nil {
+			return waitEnded(err)
+		}
+		interval = p.Config.Poll.next(interval)
+	}
+}
+
+// withStatus adapts a check that doesn't observe the status of the resource, the given status is reported while the
+// resource is not ready
+func withStatus(check func(ctx context.Context) (bool, error), status string) statusChecker {
+	return func(ctx context.Context) (bool, string, error) {
+		ready, err := check(ctx)
+		if ready {
+			return true, "", err
+		}
+		return false, status, err
+	}
+}
+
+// keepPolling checks whether the resource may be checked again after the check failed with the error: transient errors
+// are retried at the next check, and the end of the context is reported by the poller itself
+func keepPolling(ctx context.Context, err error) bool {
+	transient, _ := isTransient(err)
+	return transient || ctx.Err() != nil
+}
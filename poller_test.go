@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPollPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		interval    string
+		backoff     string
+		maxInterval string
+		policy      PollPolicy
+		err         string
+	}{
+		{
+			name:        "constant interval",
+			interval:    "10",
+			backoff:     "1",
+			maxInterval: "30",
+			policy:      PollPolicy{Interval: 10 * time.Second, Backoff: 1, MaxInterval: 30 * time.Second},
+		},
+		{
+			name:        "backoff below 1",
+			interval:    "5",
+			backoff:     "0.9",
+			maxInterval: "30",
+			err:         "plugin.poll.backoff must be at least 1: [0.9]",
+		},
+		{
+			name:        "negative interval",
+			interval:    "-5",
+			backoff:     "1.5",
+			maxInterval: "30",
+			err:         "plugin.poll.interval must not be negative: [-5]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContext(map[string]string{
+				"plugin.poll.interval":     test.interval,
+				"plugin.poll.backoff":      test.backoff,
+				"plugin.poll.max_interval": test.maxInterval,
+			})
+
+			policy, err := newPollPolicy(c)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.policy, policy)
+		})
+	}
+}
+
+func TestPollPolicy_Next(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    PollPolicy
+		intervals []time.Duration
+	}{
+		{
+			name:      "defaults",
+			policy:    PollPolicy{},
+			intervals: []time.Duration{5 * time.Second, 7500 * time.Millisecond, 11250 * time.Millisecond},
+		},
+		{
+			name:      "constant interval",
+			policy:    PollPolicy{Interval: time.Second, Backoff: 1},
+			intervals: []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:      "max interval",
+			policy:    PollPolicy{Interval: time.Second, Backoff: 3, MaxInterval: 5 * time.Second},
+			intervals: []time.Duration{time.Second, 3 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:      "max interval lower than the interval",
+			policy:    PollPolicy{Interval: 10 * time.Second, Backoff: 2, MaxInterval: 5 * time.Second},
+			intervals: []time.Duration{10 * time.Second, 10 * time.Second},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interval := test.policy.first()
+			intervals := []time.Duration{interval}
+			for len(intervals) < len(test.intervals) {
+				interval = test.policy.next(interval)
+				intervals = append(intervals, interval)
+			}
+			assert.Equal(t, test.intervals, intervals)
+		})
+	}
+}
+
+func TestPlugin_WaitForResource_Sequential(t *testing.T) {
+	p := Plugin{Config: Config{Poll: PollPolicy{Interval: 10 * time.Millisecond, Backoff: 1}}}
+
+	var running, overlaps, checks int32
+	checker := func(ctx context.Context) (bool, string, error) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+
+		// a check slower than the poll interval
+		time.Sleep(30 * time.Millisecond)
+		return atomic.AddInt32(&checks, 1) == 5, "pending", nil
+	}
+
+	assert.Nil(t, p.waitForResource(context.Background(), "test resource", time.Minute, checker))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&checks))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlaps), "the checks must not overlap")
+}
+
+func TestPlugin_WaitForResource_NoGoroutineLeak(t *testing.T) {
+	p := Plugin{Config: Config{Poll: PollPolicy{Interval: 5 * time.Millisecond, Backoff: 1}}}
+
+	notReady := func(ctx context.Context) (bool, string, error) {
+		return false, "pending", nil
+	}
+	ready := func(ctx context.Context) (bool, string, error) {
+		return true, "", nil
+	}
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		err := p.waitForResource(context.Background(), "test resource", 50*time.Millisecond, notReady)
+		assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+		assert.Nil(t, p.waitForResource(context.Background(), "test resource", time.Minute, ready))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = p.waitForResource(ctx, "test resource", time.Minute, notReady)
+		assert.Equal(t, context.Canceled, errors.Cause(err))
+	}
+
+	// give the goroutines of the runtime a moment to settle
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, runtime.NumGoroutine() <= before, "goroutines leaked: %d before, %d after", before,
+		runtime.NumGoroutine())
+}
+
+func TestTimeouts_OrDefault(t *testing.T) {
+	timeouts := Timeouts{ClusterCreate: 40 * time.Minute, Deadline: time.Hour}.orDefault(10 * time.Minute)
+
+	assert.Equal(t, Timeouts{
+		ClusterCreate: 40 * time.Minute,
+		ClusterDelete: 10 * time.Minute,
+		Helm:          10 * time.Minute,
+		Release:       10 * time.Minute,
+		Endpoint:      10 * time.Minute,
+		Deadline:      time.Hour,
+	}, timeouts)
+}
+
+func TestPlugin_WaitForResource_Deadline(t *testing.T) {
+	p := Plugin{Config: Config{Poll: PollPolicy{Interval: 10 * time.Millisecond, Backoff: 1}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := p.waitForResource(ctx, "cluster [test-cluster] creation", time.Minute, func(ctx context.Context) (bool, string, error) {
+		return false, "CREATING", nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	assert.EqualError(t, err, "step deadline exceeded waiting for cluster [test-cluster] creation after 0s, last status: [CREATING]")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	. "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/amazon"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	amazonDefaultInstanceType = "m4.xlarge" // 4 vCPU, 16 GB RAM, General Purpose
+	amazonDefaultLocation     = "eu-west-1"
+)
+
+// amazonDefaultImages the images used by location if the image is not set and the cloud info doesn't provide one
+var amazonDefaultImages = map[string]string{
+	"eu-west-1": "ami-16bfeb6f",
+}
+
+type amazonProvider struct{}
+
+func init() {
+	registerClusterProvider(constants.Amazon, amazonProvider{})
+}
+
+func (amazonProvider) defaultLocation() string {
+	return amazonDefaultLocation
+}
+
+func (amazonProvider) setDefaults(c *cli.Context) {
+	if c.String("plugin.node.instance_type") == "" {
+		c.Set("plugin.node.instance_type", amazonDefaultInstanceType)
+	}
+
+	if c.String("plugin.amazon.master.instance_type") == "" {
+		c.Set("plugin.amazon.master.instance_type", amazonDefaultInstanceType)
+	}
+}
+
+func (amazonProvider) createProperties(c *cli.Context, cluster *CustomCluster) error {
+	nodePools, err := nodePoolsOf(c, constants.Amazon, NodePool{
+		InstanceType: c.String("plugin.node.instance_type"),
+		MinCount:     c.Int("plugin.amazon.node.min_count"),
+		MaxCount:     c.Int("plugin.amazon.node.max_count"),
+		SpotPrice:    c.String("plugin.amazon.node.spot_price"),
+		Image:        c.String("plugin.amazon.node.image"),
+	})
+	if err != nil {
+		return err
+	}
+
+	cluster.Properties.CreateClusterAmazon = &amazon.CreateClusterAmazon{
+		NodePools: nodePools.amazon(),
+		Master: &amazon.CreateAmazonMaster{
+			InstanceType: c.String("plugin.amazon.master.instance_type"),
+			Image:        c.String("plugin.amazon.master.image"),
+		},
+	}
+
+	return nil
+}
+
+func (amazonProvider) updateProperties(createRequest *CreateClusterRequest) UpdateProperties {
+	createAmazon := createRequest.Properties.CreateClusterAmazon
+	if createAmazon == nil {
+		return UpdateProperties{}
+	}
+
+	updateAmazon := &amazon.UpdateClusterAmazon{
+		NodePools: map[string]*amazon.UpdateAmazonNodePool{},
+	}
+	for name, nodePool := range createAmazon.NodePools {
+		updateAmazon.NodePools[name] = &amazon.UpdateAmazonNodePool{
+			MinCount: nodePool.MinCount,
+			MaxCount: nodePool.MaxCount,
+		}
+	}
+
+	return UpdateProperties{Amazon: updateAmazon}
+}
+
+func (amazonProvider) cloudInfoSpec(createRequest *CreateClusterRequest) *cloudInfoSpec {
+	spec := &cloudInfoSpec{}
+
+	createAmazon := createRequest.Properties.CreateClusterAmazon
+	if createAmazon == nil {
+		return spec
+	}
+
+	if createAmazon.Master != nil {
+		spec.InstanceTypes = appendUnique(spec.InstanceTypes, createAmazon.Master.InstanceType)
+		spec.Images = appendUnique(spec.Images, createAmazon.Master.Image)
+	}
+	for _, nodePool := range createAmazon.NodePools {
+		spec.InstanceTypes = appendUnique(spec.InstanceTypes, nodePool.InstanceType)
+		spec.Images = appendUnique(spec.Images, nodePool.Image)
+	}
+
+	sort.Strings(spec.InstanceTypes)
+	sort.Strings(spec.Images)
+	return spec
+}
+
+// kubernetesVersions the kubernetes version of amazon clusters is determined by the image
+func (amazonProvider) kubernetesVersions(createRequest *CreateClusterRequest) map[string]*string {
+	return nil
+}
+
+// resolveAmazonImages sets the images of the master and the node pools that are not set to the image matching the
+// location of the cluster. The image is looked up in the Pipeline cloud info, the built-in images are used if the
+// cloud info is not available or has no image for the location.
+func (p *Plugin) resolveAmazonImages(ctx context.Context) error {
+	createAmazon := p.Config.Cluster.Properties.CreateClusterAmazon
+	if createAmazon == nil {
+		return nil
+	}
+
+	var images []*string
+	if createAmazon.Master != nil && createAmazon.Master.Image == "" {
+		images = append(images, &createAmazon.Master.Image)
+	}
+	for _, nodePool := range createAmazon.NodePools {
+		if nodePool.Image == "" {
+			images = append(images, &nodePool.Image)
+		}
+	}
+
+	if len(images) == 0 {
+		return nil
+	}
+
+	image, err := p.lookupAmazonImage(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range images {
+		*i = image
+	}
+
+	return nil
+}
+
+// lookupAmazonImage returns the image to be used in the location of the cluster
+func (p *Plugin) lookupAmazonImage(ctx context.Context) (string, error) {
+	location := p.Config.Cluster.Location
+
+	cloudInfo, err := p.getCloudInfo(ctx, p.newCloudInfoRequest(constants.KeyWordImage))
+	if err == nil && len(cloudInfo.Image[location]) > 0 {
+		image := cloudInfo.Image[location][0]
+		log.Infof("using image [%s] in location [%s]", image, location)
+		return image, nil
+	}
+
+	if err != nil {
+		log.Warnf("could not look up image in cloud info: [%s]", err.Error())
+	} else {
+		log.Warnf("no image of location [%s] in cloud info", location)
+	}
+
+	image, ok := amazonDefaultImages[location]
+	if !ok {
+		return "", errors.Errorf("no image known for location [%s], please set the amazon_node_image and amazon_master_image options", location)
+	}
+
+	log.Infof("using default image [%s] in location [%s]", image, location)
+	return image, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sort"
+
+	. "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/azure"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/urfave/cli"
+)
+
+const (
+	azureDefaultInstanceType = "Standard_B4ms" // 4 vCPU, 16 GB RAM, Burstable VM
+	azureDefaultLocation     = "eastus"
+)
+
+type azureProvider struct{}
+
+func init() {
+	registerClusterProvider(constants.Azure, azureProvider{})
+}
+
+func (azureProvider) defaultLocation() string {
+	return azureDefaultLocation
+}
+
+func (azureProvider) setDefaults(c *cli.Context) {
+	if c.String("plugin.node.instance_type") == "" {
+		c.Set("plugin.node.instance_type", azureDefaultInstanceType)
+	}
+
+	if c.String("plugin.azure.agent_name") == "" {
+		c.Set("plugin.azure.agent_name", c.String("plugin.cluster.name"))
+	}
+}
+
+func (azureProvider) createProperties(c *cli.Context, cluster *CustomCluster) error {
+	nodeCount := c.Int("plugin.azure.node.count")
+	if nodeCount == 0 {
+		nodeCount = constants.AzureDefaultAgentCount
+	}
+
+	nodePools, err := nodePoolsOf(c, constants.Azure, NodePool{
+		InstanceType: c.String("plugin.node.instance_type"),
+		Count:        nodeCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	cluster.Properties.CreateClusterAzure = &azure.CreateClusterAzure{
+		NodePools:         nodePools.azure(),
+		KubernetesVersion: c.String("plugin.azure.kubernetes_version"),
+		ResourceGroup:     c.String("plugin.azure.resource_group"),
+	}
+
+	return nil
+}
+
+func (azureProvider) updateProperties(createRequest *CreateClusterRequest) UpdateProperties {
+	createAzure := createRequest.Properties.CreateClusterAzure
+	if createAzure == nil {
+		return UpdateProperties{}
+	}
+
+	updateAzure := &azure.UpdateClusterAzure{
+		NodePools: map[string]*azure.NodePoolUpdate{},
+	}
+	for name, nodePool := range createAzure.NodePools {
+		updateAzure.NodePools[name] = &azure.NodePoolUpdate{
+			Count: nodePool.Count,
+		}
+	}
+
+	return UpdateProperties{Azure: updateAzure}
+}
+
+func (azureProvider) cloudInfoSpec(createRequest *CreateClusterRequest) *cloudInfoSpec {
+	spec := &cloudInfoSpec{}
+
+	createAzure := createRequest.Properties.CreateClusterAzure
+	if createAzure == nil {
+		return spec
+	}
+
+	spec.KubernetesVersions = appendUnique(spec.KubernetesVersions, createAzure.KubernetesVersion)
+	for _, nodePool := range createAzure.NodePools {
+		spec.InstanceTypes = appendUnique(spec.InstanceTypes, nodePool.NodeInstanceType)
+	}
+
+	sort.Strings(spec.InstanceTypes)
+	return spec
+}
+
+func (azureProvider) kubernetesVersions(createRequest *CreateClusterRequest) map[string]*string {
+	createAzure := createRequest.Properties.CreateClusterAzure
+	if createAzure == nil {
+		return nil
+	}
+
+	return map[string]*string{
+		"KUBERNETES_VERSION": &createAzure.KubernetesVersion,
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	. "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/dummy"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/urfave/cli"
+)
+
+const (
+	dummyDefaultLocation          = "dummy-location"
+	dummyDefaultNodeCount         = 1
+	dummyDefaultKubernetesVersion = "1.10.0"
+)
+
+type dummyProvider struct{}
+
+func init() {
+	registerClusterProvider(constants.Dummy, dummyProvider{})
+}
+
+func (dummyProvider) defaultLocation() string {
+	return dummyDefaultLocation
+}
+
+func (dummyProvider) setDefaults(c *cli.Context) {}
+
+func (dummyProvider) createProperties(c *cli.Context, cluster *CustomCluster) error {
+	nodeCount := c.Int("plugin.dummy.node.count")
+	if nodeCount == 0 {
+		nodeCount = dummyDefaultNodeCount
+	}
+
+	kubernetesVersion := c.String("plugin.dummy.kubernetes_version")
+	if kubernetesVersion == "" {
+		kubernetesVersion = dummyDefaultKubernetesVersion
+	}
+
+	cluster.Properties.CreateClusterDummy = &dummy.CreateClusterDummy{
+		Node: &dummy.Node{
+			KubernetesVersion: kubernetesVersion,
+			Count:             nodeCount,
+		},
+	}
+
+	return nil
+}
+
+func (dummyProvider) updateProperties(createRequest *CreateClusterRequest) UpdateProperties {
+	createDummy := createRequest.Properties.CreateClusterDummy
+	if createDummy == nil {
+		return UpdateProperties{}
+	}
+
+	return UpdateProperties{
+		Dummy: &dummy.UpdateClusterDummy{
+			Node: createDummy.Node,
+		},
+	}
+}
+
+// cloudInfoSpec there is no cloud info for the dummy cloud
+func (dummyProvider) cloudInfoSpec(createRequest *CreateClusterRequest) *cloudInfoSpec {
+	return nil
+}
+
+// kubernetesVersions the kubernetes version of dummy clusters is not resolved
+func (dummyProvider) kubernetesVersions(createRequest *CreateClusterRequest) map[string]*string {
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+
+	. "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/google"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/urfave/cli"
+)
+
+const (
+	googleDefaultInstanceType = "n1-standard-4" // 4 vCPUs 15 GB RAM. Standard machine
+	googleDefaultLocation     = "us-central1-a"
+)
+
+type googleProvider struct{}
+
+func init() {
+	registerClusterProvider(constants.Google, googleProvider{})
+}
+
+func (googleProvider) defaultLocation() string {
+	return googleDefaultLocation
+}
+
+func (googleProvider) setDefaults(c *cli.Context) {
+	if c.String("plugin.node.instance_type") == "" {
+		c.Set("plugin.node.instance_type", googleDefaultInstanceType)
+	}
+}
+
+func (googleProvider) createProperties(c *cli.Context, cluster *CustomCluster) error {
+	nodePools, err := nodePoolsOf(c, constants.Google, NodePool{
+		InstanceType:   c.String("plugin.node.instance_type"),
+		Count:          c.Int("plugin.google.node.count"),
+		ServiceAccount: c.String("plugin.google.service.account"),
+	})
+	if err != nil {
+		return err
+	}
+
+	cluster.Properties.CreateClusterGoogle = &google.CreateClusterGoogle{
+		Project: c.String("plugin.google.project"),
+		Master: &google.Master{
+			Version: c.String("plugin.google.gke.version"),
+		},
+		NodeVersion: c.String("plugin.google.gke.version"),
+		NodePools:   nodePools.google(),
+	}
+
+	return nil
+}
+
+func (googleProvider) updateProperties(createRequest *CreateClusterRequest) UpdateProperties {
+	createGoogle := createRequest.Properties.CreateClusterGoogle
+	if createGoogle == nil {
+		return UpdateProperties{}
+	}
+
+	updateGoogle := &google.UpdateClusterGoogle{
+		NodeVersion: createGoogle.NodeVersion,
+		NodePools:   map[string]*google.NodePool{},
+	}
+	for name, nodePool := range createGoogle.NodePools {
+		updateGoogle.NodePools[name] = &google.NodePool{
+			Count: nodePool.Count,
+		}
+	}
+	// the versions are only sent if they were asked for, otherwise the cluster would be upgraded with every update
+	if createGoogle.Master != nil && createGoogle.Master.Version != "" {
+		updateGoogle.Master = createGoogle.Master
+	}
+
+	return UpdateProperties{Google: updateGoogle}
+}
+
+func (googleProvider) cloudInfoSpec(createRequest *CreateClusterRequest) *cloudInfoSpec {
+	spec := &cloudInfoSpec{}
+
+	createGoogle := createRequest.Properties.CreateClusterGoogle
+	if createGoogle == nil {
+		return spec
+	}
+
+	if createGoogle.Master != nil {
+		spec.KubernetesVersions = appendUnique(spec.KubernetesVersions, createGoogle.Master.Version)
+	}
+	spec.KubernetesVersions = appendUnique(spec.KubernetesVersions, createGoogle.NodeVersion)
+	for _, nodePool := range createGoogle.NodePools {
+		spec.InstanceTypes = appendUnique(spec.InstanceTypes, nodePool.NodeInstanceType)
+	}
+
+	sort.Strings(spec.InstanceTypes)
+	return spec
+}
+
+func (googleProvider) kubernetesVersions(createRequest *CreateClusterRequest) map[string]*string {
+	createGoogle := createRequest.Properties.CreateClusterGoogle
+	if createGoogle == nil {
+		return nil
+	}
+
+	versions := map[string]*string{
+		"KUBERNETES_NODE_VERSION": &createGoogle.NodeVersion,
+	}
+	if createGoogle.Master != nil {
+		versions["KUBERNETES_MASTER_VERSION"] = &createGoogle.Master.Version
+	}
+	return versions
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+
+	. "github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/kubernetes"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+type kubernetesProvider struct{}
+
+func init() {
+	registerClusterProvider(constants.Kubernetes, kubernetesProvider{})
+}
+
+// defaultLocation imported clusters have no location
+func (kubernetesProvider) defaultLocation() string {
+	return ""
+}
+
+func (kubernetesProvider) setDefaults(c *cli.Context) {}
+
+// createProperties the kubeconfig is only read once the cluster is going to be imported, it's not needed to reuse or
+// delete the cluster
+func (kubernetesProvider) createProperties(c *cli.Context, cluster *CustomCluster) error {
+	metadata, err := parseMetadata(c.String("plugin.kubernetes.metadata"))
+	if err != nil {
+		return err
+	}
+
+	cluster.KubeConfig = c.String("plugin.kubernetes.config")
+	cluster.KubeConfigFile = c.String("plugin.kubernetes.config_file")
+	cluster.Properties.CreateKubernetes = &kubernetes.CreateKubernetes{
+		Metadata: metadata,
+	}
+
+	return nil
+}
+
+// updateProperties imported clusters can't be updated through Pipeline
+func (kubernetesProvider) updateProperties(createRequest *CreateClusterRequest) UpdateProperties {
+	return UpdateProperties{}
+}
+
+// cloudInfoSpec there is no cloud info for imported clusters
+func (kubernetesProvider) cloudInfoSpec(createRequest *CreateClusterRequest) *cloudInfoSpec {
+	return nil
+}
+
+// kubernetesVersions the kubernetes version of imported clusters is given
+func (kubernetesProvider) kubernetesVersions(createRequest *CreateClusterRequest) map[string]*string {
+	return nil
+}
+
+// readKubeConfig returns the kubeconfig of the cluster to be imported; the inline configuration takes precedence over
+// the configuration file, which is resolved relative to the workspace
+func readKubeConfig(kubeConfig string, kubeConfigFile string, workspace string) (string, error) {
+	if kubeConfig != "" {
+		return kubeConfig, nil
+	}
+
+	if kubeConfigFile == "" {
+		return "", errors.New("either the kubeconfig or the kubeconfig file is required for kubernetes clusters")
+	}
+
+	kubeConfigFile = workspacePath(kubeConfigFile, workspace)
+
+	kubeConfigBytes, err := ioutil.ReadFile(kubeConfigFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read kubeconfig file: [%s]", kubeConfigFile)
+	}
+
+	return string(kubeConfigBytes), nil
+}
+
+// parseMetadata parses the metadata of the imported cluster; both YAML and JSON documents are accepted
+func parseMetadata(metadataStr string) (map[string]string, error) {
+	metadata := map[string]string{}
+
+	if metadataStr == "" {
+		return metadata, nil
+	}
+
+	err := yaml.UnmarshalStrict([]byte(metadataStr), &metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse cluster metadata")
+	}
+
+	return metadata, nil
+}
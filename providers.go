@@ -0,0 +1,88 @@
+package main
+
+import (
+	. "github.com/banzaicloud/banzai-types/components"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// clusterProvider assembles the cloud specific part of the cluster requests from the plugin settings
+type clusterProvider interface {
+	// defaultLocation the location the cluster is created in if none is specified
+	defaultLocation() string
+
+	// setDefaults fills the unset cloud specific settings with defaults
+	setDefaults(c *cli.Context)
+
+	// createProperties sets the cloud specific properties of the cluster creation request
+	createProperties(c *cli.Context, cluster *CustomCluster) error
+
+	// updateProperties derives the properties of the cluster update request from the creation request
+	updateProperties(createRequest *CreateClusterRequest) UpdateProperties
+
+	// cloudInfoSpec collects the values of the creation request to be checked against the Pipeline cloud info,
+	// nil if the cloud has no cloud info
+	cloudInfoSpec(createRequest *CreateClusterRequest) *cloudInfoSpec
+
+	// kubernetesVersions returns the kubernetes version fields of the creation request keyed by the name they are
+	// exported with once resolved
+	kubernetesVersions(createRequest *CreateClusterRequest) map[string]*string
+}
+
+// clusterProviders the supported cloud providers keyed by cloud type
+var clusterProviders = map[string]clusterProvider{}
+
+// registerClusterProvider makes the provider available for the given cloud type
+func registerClusterProvider(cloud string, provider clusterProvider) {
+	clusterProviders[cloud] = provider
+}
+
+// getClusterProvider returns the provider registered for the given cloud type
+func getClusterProvider(cloud string) (clusterProvider, error) {
+	provider, ok := clusterProviders[cloud]
+	if !ok {
+		return nil, errors.Errorf("not supported cluster provider: [%s]", cloud)
+	}
+	return provider, nil
+}
+
+// newCustomCluster assembles the cluster based on the settings with only the properties of the selected cloud set
+func newCustomCluster(c *cli.Context, provider clusterProvider) (*CustomCluster, error) {
+	cluster := &CustomCluster{
+		CreateClusterRequest: &CreateClusterRequest{
+			Name:     c.String("plugin.cluster.name"),
+			Location: c.String("plugin.cluster.location"),
+			Cloud:    c.String("plugin.cluster.provider"),
+			SecretId: c.String("plugin.secret.id"),
+		},
+		State: c.String("plugin.cluster.state"),
+	}
+
+	err := provider.createProperties(c, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return cluster, nil
+}
+
+// nodePoolsOf returns the node pools declared in the settings, or the single default node pool if none are declared,
+// completed with the given defaults and validated against the constraints of the cloud
+func nodePoolsOf(c *cli.Context, cloud string, defaults NodePool) (NodePools, error) {
+	nodePools := NodePools{defaultNodePoolName: &NodePool{}}
+
+	if nodePoolsStr := c.String("plugin.cluster.node_pools"); nodePoolsStr != "" {
+		var err error
+		nodePools, err = parseNodePools(nodePoolsStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := nodePools.withDefaults(defaults).validate(cloud)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid node pools")
+	}
+
+	return nodePools, nil
+}
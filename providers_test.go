@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components"
+	"github.com/banzaicloud/banzai-types/components/amazon"
+	"github.com/banzaicloud/banzai-types/components/dummy"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+// newTestContext creates a cli context holding the given string settings
+func newTestContext(settings map[string]string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for name, value := range settings {
+		set.String(name, value, "")
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestNewCustomCluster(t *testing.T) {
+	c := newTestContext(map[string]string{
+		"plugin.cluster.name":         "test-cluster",
+		"plugin.cluster.provider":     constants.Azure,
+		"plugin.cluster.location":     "",
+		"plugin.cluster.state":        createdState,
+		"plugin.cluster.node_pools":   `{"system":{"count":2},"batch":{"instance_type":"Standard_D16s_v3","count":5}}`,
+		"plugin.node.instance_type":   "",
+		"plugin.azure.node.count":     "",
+		"plugin.azure.resource_group": "test-group",
+		"plugin.azure.agent_name":     "",
+		"plugin.amazon.node.image":    "ami-16bfeb6f",
+	})
+
+	provider, err := getClusterProvider(c.String("plugin.cluster.provider"))
+	assert.Nil(t, err)
+	setDefaults(c, provider)
+
+	cluster, err := newCustomCluster(c, provider)
+	assert.Nil(t, err)
+	assert.Nil(t, cluster.Validate())
+
+	assert.Equal(t, azureDefaultLocation, cluster.Location)
+	assert.Nil(t, cluster.Properties.CreateClusterAmazon, "properties of other clouds must not be set")
+	assert.Nil(t, cluster.Properties.CreateClusterGoogle, "properties of other clouds must not be set")
+	assert.Equal(t, "test-group", cluster.Properties.CreateClusterAzure.ResourceGroup)
+	assert.Equal(t, azureDefaultInstanceType, cluster.Properties.CreateClusterAzure.NodePools["system"].NodeInstanceType)
+	assert.Equal(t, 5, cluster.Properties.CreateClusterAzure.NodePools["batch"].Count)
+
+	updateRequest := newUpdateClusterRequest(cluster.CreateClusterRequest)
+	assert.Nil(t, updateRequest.Validate())
+	assert.Equal(t, 2, updateRequest.Azure.NodePools["system"].Count)
+
+	c = newTestContext(map[string]string{
+		"plugin.cluster.name":             "test-cluster",
+		"plugin.cluster.provider":         constants.Dummy,
+		"plugin.cluster.location":         "",
+		"plugin.cluster.state":            createdState,
+		"plugin.dummy.node.count":         "",
+		"plugin.dummy.kubernetes_version": "",
+	})
+
+	provider, err = getClusterProvider(c.String("plugin.cluster.provider"))
+	assert.Nil(t, err)
+	setDefaults(c, provider)
+
+	cluster, err = newCustomCluster(c, provider)
+	assert.Nil(t, err)
+	assert.Nil(t, cluster.Validate())
+
+	assert.Equal(t, dummyDefaultLocation, cluster.Location)
+	assert.Nil(t, cluster.Properties.CreateClusterAmazon, "properties of other clouds must not be set")
+	assert.Equal(t, &dummy.Node{KubernetesVersion: dummyDefaultKubernetesVersion, Count: dummyDefaultNodeCount},
+		cluster.Properties.CreateClusterDummy.Node)
+
+	updateRequest = newUpdateClusterRequest(cluster.CreateClusterRequest)
+	assert.Nil(t, updateRequest.Validate())
+	assert.Equal(t, &dummy.Node{KubernetesVersion: dummyDefaultKubernetesVersion, Count: dummyDefaultNodeCount},
+		updateRequest.Dummy.Node)
+}
+
+func TestGetClusterProvider(t *testing.T) {
+	for _, cloud := range []string{constants.Amazon, constants.Azure, constants.Google, constants.Dummy, constants.Kubernetes} {
+		_, err := getClusterProvider(cloud)
+		assert.Nil(t, err, "provider must be registered for %s", cloud)
+	}
+
+	_, err := getClusterProvider("unknown")
+	assert.EqualError(t, err, "not supported cluster provider: [unknown]")
+}
+
+func TestPlugin_ResolveAmazonImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   string
+		statusCode int
+		body       string
+		image      string
+		err        string
+	}{
+		{
+			name:       "image from cloud info",
+			location:   "eu-central-1",
+			statusCode: http.StatusOK,
+			body:       `{"type":"amazon","image":{"eu-central-1":["ami-cloudinfo"]}}`,
+			image:      "ami-cloudinfo",
+		},
+		{
+			name:       "built-in image if cloud info is unavailable",
+			location:   "eu-west-1",
+			statusCode: http.StatusInternalServerError,
+			image:      amazonDefaultImages["eu-west-1"],
+		},
+		{
+			name:       "built-in image if cloud info has no image for the location",
+			location:   "eu-west-1",
+			statusCode: http.StatusOK,
+			body:       `{"type":"amazon","image":{"eu-central-1":["ami-cloudinfo"]}}`,
+			image:      amazonDefaultImages["eu-west-1"],
+		},
+		{
+			name:       "unknown location",
+			location:   "ap-south-1",
+			statusCode: http.StatusInternalServerError,
+			err:        "no image known for location [ap-south-1], please set the amazon_node_image and amazon_master_image options",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			createRequest := &components.CreateClusterRequest{Name: "test-cluster", Location: test.location, Cloud: constants.Amazon}
+			createRequest.Properties.CreateClusterAmazon = &amazon.CreateClusterAmazon{
+				Master: &amazon.CreateAmazonMaster{InstanceType: "m4.xlarge"},
+				NodePools: map[string]*amazon.AmazonNodePool{
+					"pool1": {InstanceType: "m4.xlarge"},
+					"pool2": {InstanceType: "m4.xlarge", Image: "ami-custom"},
+				},
+			}
+
+			p := Plugin{
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: test.statusCode,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(test.body))),
+					}, nil
+				},
+				Config: Config{
+					Cluster: &CustomCluster{CreateClusterRequest: createRequest},
+				},
+			}
+
+			err := p.resolveAmazonImages(context.Background())
+			if test.err != "" {
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.image, createRequest.Properties.CreateClusterAmazon.Master.Image)
+			assert.Equal(t, test.image, createRequest.Properties.CreateClusterAmazon.NodePools["pool1"].Image)
+			assert.Equal(t, "ami-custom", createRequest.Properties.CreateClusterAmazon.NodePools["pool2"].Image)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/drone-plugin-pipeline-client/client"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// retryBaseDelay the delay before the first retry, doubled for every further retry
+	retryBaseDelay = time.Second
+	// retryMaxDelay the upper limit of the delay between two attempts
+	retryMaxDelay = 30 * time.Second
+)
+
+// RetryPolicy controls how API calls failing with transient errors are retried
+type RetryPolicy struct {
+	// MaxAttempts the number of attempts including the first one, retries are disabled if it's less than 2
+	MaxAttempts int
+	// Budget the total time the attempts and the delays between them may take
+	Budget time.Duration
+}
+
+// backoff tracks the attempts of a single call retried according to the policy
+type backoff struct {
+	policy  RetryPolicy
+	attempt int
+	start   time.Time
+}
+
+func (policy RetryPolicy) newBackoff() *backoff {
+	return &backoff{
+		policy:  policy,
+		attempt: 1,
+		start:   time.Now(),
+	}
+}
+
+// next returns the delay before the next attempt, false is returned if the attempts or the budget of the policy are
+// exhausted. The delay grows exponentially with full jitter unless the server requested a delay (retryAfter)
+func (b *backoff) next(retryAfter time.Duration) (time.Duration, bool) {
+	if b.attempt >= b.policy.MaxAttempts {
+		return 0, false
+	}
+
+	delay := retryAfter
+	if delay <= 0 {
+		maxDelay := retryBaseDelay << uint(b.attempt-1)
+		if maxDelay > retryMaxDelay || maxDelay <= 0 {
+			maxDelay = retryMaxDelay
+		}
+		delay = time.Duration(rand.Int63n(int64(maxDelay)) + 1)
+	}
+
+	if time.Since(b.start)+delay > b.policy.Budget {
+		return 0, false
+	}
+
+	b.attempt++
+	return delay, true
+}
+
+// isIdempotent checks whether the request can be sent again without checking its outcome first
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTransientStatus checks whether the status code is likely the result of a temporary failure (e.g. a load balancer
+// dropping the connection to Pipeline)
+func isTransientStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTransient checks whether the error is a network error or a transient error response, the delay requested by the
+// server is returned as well
+func isTransient(err error) (bool, time.Duration) {
+	switch cause := errors.Cause(err).(type) {
+	case *client.Error:
+		return isTransientStatus(cause.StatusCode), cause.RetryAfter
+	case net.Error:
+		return !isCertificateError(cause), 0
+	default:
+		return false, 0
+	}
+}
+
+// isCertificateError checks whether the error is caused by a failed TLS handshake, e.g. an unknown certificate
+// authority or a rejected client certificate; the handshake fails the same way if the request is sent again
+func isCertificateError(err error) bool {
+	for {
+		switch cause := err.(type) {
+		case *url.Error:
+			err = cause.Err
+		case *net.OpError:
+			err = cause.Err
+		case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError, x509.SystemRootsError,
+			x509.ConstraintViolationError, tls.RecordHeaderError:
+			return true
+		default:
+			// the alerts of the TLS protocol and the handshake errors are reported with the tls prefix
+			return err != nil && strings.HasPrefix(err.Error(), "tls: ")
+		}
+	}
+}
+
+// createWithRetry calls create, if it fails with a transient error the resource might have been created nevertheless,
+// so the existence of the resource is checked before create is retried
+func (p *Plugin) createWithRetry(ctx context.Context, resource string, create func(ctx context.Context) error,
+	exists func(ctx context.Context) (bool, error)) error {
+	retries := p.Config.Retry.newBackoff()
+
+	for {
+		err := create(ctx)
+		if err == nil {
+			return nil
+		}
+
+		transient, retryAfter := isTransient(err)
+		if !transient || ctx.Err() != nil {
+			return err
+		}
+
+		delay, ok := retries.next(retryAfter)
+		if !ok {
+			return err
+		}
+
+		log.Warnf("creating %s failed, checking its existence in %s: [%s]", resource, delay, err.Error())
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+
+		found, existsErr := exists(ctx)
+		if existsErr != nil {
+			// creating it again might result in a duplicate
+			log.Errorf("could not check the existence of %s: [%s]", resource, existsErr.Error())
+			return err
+		}
+		if found {
+			log.Infof("%s has been created despite the failed request", resource)
+			return nil
+		}
+	}
+}
+
+// sleep pauses for the given duration, the error of the context is returned if it's done in the meantime
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
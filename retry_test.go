@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/drone-plugin-pipeline-client/client"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Next(t *testing.T) {
+	retries := RetryPolicy{MaxAttempts: 3, Budget: time.Minute}.newBackoff()
+
+	delay, ok := retries.next(0)
+	assert.True(t, ok)
+	assert.True(t, delay > 0 && delay <= retryBaseDelay, "the first delay must not exceed the base delay")
+
+	delay, ok = retries.next(5 * time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, delay, "the delay requested by the server must be honored")
+
+	_, ok = retries.next(0)
+	assert.False(t, ok, "the attempts must be exhausted")
+
+	retries = RetryPolicy{MaxAttempts: 3, Budget: time.Second}.newBackoff()
+	_, ok = retries.next(2 * time.Second)
+	assert.False(t, ok, "the budget must be exhausted")
+}
+
+func TestApiCall_Retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		attempts int
+		status   int
+		calls    int
+	}{
+		{
+			name:     "transient failure is retried",
+			method:   http.MethodGet,
+			attempts: 3,
+			status:   http.StatusOK,
+			calls:    2,
+		},
+		{
+			name:     "retries disabled",
+			method:   http.MethodGet,
+			attempts: 1,
+			status:   http.StatusBadGateway,
+			calls:    1,
+		},
+		{
+			name:     "not idempotent request is not retried",
+			method:   http.MethodPost,
+			attempts: 3,
+			status:   http.StatusBadGateway,
+			calls:    1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			config := &Config{Token: "token", Retry: RetryPolicy{MaxAttempts: test.attempts, Budget: time.Minute}}
+			resp, err := ApiCall(context.Background(), config, server.URL, test.method, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, test.calls, calls)
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{
+			name:      "bad gateway",
+			err:       &client.Error{StatusCode: http.StatusBadGateway},
+			transient: true,
+		},
+		{
+			name: "internal server error",
+			err:  &client.Error{StatusCode: http.StatusInternalServerError},
+		},
+		{
+			name:      "connection refused",
+			err:       &url.Error{Op: "Get", URL: "https://pipeline", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}},
+			transient: true,
+		},
+		{
+			name: "unknown certificate authority",
+			err:  &url.Error{Op: "Get", URL: "https://pipeline", Err: x509.UnknownAuthorityError{}},
+		},
+		{
+			name: "certificate of another host",
+			err:  errors.Wrap(&url.Error{Op: "Get", URL: "https://pipeline", Err: x509.HostnameError{Host: "pipeline"}}, "failed to call"),
+		},
+		{
+			name: "client certificate rejected",
+			err:  &url.Error{Op: "Get", URL: "https://pipeline", Err: &net.OpError{Op: "remote error", Err: errors.New("tls: bad certificate")}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transient, _ := isTransient(test.err)
+			assert.Equal(t, test.transient, transient)
+		})
+	}
+}
+
+func TestApiCall_CertificateError(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	// the certificate of the test server is not trusted by the default client
+	config := &Config{Token: "token", Retry: RetryPolicy{MaxAttempts: 5, Budget: time.Minute}}
+	_, err := ApiCall(context.Background(), config, server.URL, http.MethodGet, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections), "certificate errors must not be retried")
+}
+
+func TestApiCall_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		cancel()
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := &Config{Token: "token", Retry: RetryPolicy{MaxAttempts: 5, Budget: 5 * time.Minute}}
+
+	start := time.Now()
+	_, err := ApiCall(ctx, config, server.URL, http.MethodGet, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+	assert.Equal(t, 1, calls)
+	assert.True(t, time.Since(start) < 10*time.Second, "the retries must stop as soon as the context is canceled")
+}
+
+func TestPlugin_CreateWithRetry(t *testing.T) {
+	transientErr := &client.Error{Operation: "create cluster", StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+
+	tests := []struct {
+		name      string
+		createErr error
+		exists    bool
+		creates   int
+		err       string
+	}{
+		{
+			name:      "created despite the failed request",
+			createErr: transientErr,
+			exists:    true,
+			creates:   1,
+		},
+		{
+			name:      "created again",
+			createErr: transientErr,
+			creates:   2,
+		},
+		{
+			name:      "permanent failure",
+			createErr: errors.New("invalid cluster"),
+			creates:   1,
+			err:       "invalid cluster",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Plugin{Config: Config{Retry: RetryPolicy{MaxAttempts: 3, Budget: time.Minute}}}
+
+			creates := 0
+			create := func(ctx context.Context) error {
+				creates++
+				if creates == 1 {
+					return test.createErr
+				}
+				return nil
+			}
+			exists := func(ctx context.Context) (bool, error) {
+				return test.exists, nil
+			}
+
+			err := p.createWithRetry(context.Background(), "cluster [test-cluster]", create, exists)
+			assert.Equal(t, test.creates, creates)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenLeeway the allowed clock skew between the build agent and Pipeline when the token is checked
+const tokenLeeway = time.Minute
+
+// tokenClaims the claims of the JWT tokens issued by Pipeline
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Issuer    string `json:"iss"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+	IssuedAt  int64  `json:"iat"`
+
+	// Type the type of the token (e.g. "ci" for the tokens of the CI/CD flow)
+	Type string `json:"type"`
+	// Text the login of the user the token was issued for; the virtual users of the CI/CD flow are named
+	// <organization>/<repository>
+	Text string `json:"text"`
+}
+
+// parseTokenClaims decodes the claims of the JWT token, the signature of the token is not verified
+func parseTokenClaims(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode the token claims")
+	}
+
+	claims := &tokenClaims{}
+	err = json.Unmarshal(payload, claims)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse the token claims")
+	}
+
+	return claims, nil
+}
+
+// validate checks whether the token is valid at the given time
+func (claims *tokenClaims) validate(now time.Time) error {
+	if claims.ExpiresAt > 0 {
+		expiresAt := time.Unix(claims.ExpiresAt, 0)
+		if now.After(expiresAt.Add(tokenLeeway)) {
+			return errors.Errorf("the token of [%s] expired at [%s]", claims.user(), expiresAt.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if claims.NotBefore > 0 {
+		notBefore := time.Unix(claims.NotBefore, 0)
+		if now.Before(notBefore.Add(-tokenLeeway)) {
+			return errors.Errorf("the token of [%s] is not valid before [%s]", claims.user(), notBefore.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// user the user the token was issued for
+func (claims *tokenClaims) user() string {
+	if claims.Text != "" {
+		return claims.Text
+	}
+	return claims.Subject
+}
+
+// orgName the name of the organization the token was issued in, empty if it's not a CI/CD token
+func (claims *tokenClaims) orgName() string {
+	if i := strings.Index(claims.Text, "/"); i > 0 {
+		return claims.Text[:i]
+	}
+	return ""
+}
+
+// checkToken checks the validity of the bearer token locally, so that an expired token is reported clearly instead of
+// failing the first API call. Tokens that are not JWTs are left to Pipeline to check.
+func (p *Plugin) checkToken() error {
+	if p.Config.Token == "" {
+		return nil
+	}
+
+	claims, err := parseTokenClaims(p.Config.Token)
+	if err != nil {
+		log.Debugf("skipping token check: [%s]", err.Error())
+		return nil
+	}
+
+	log.Debugf("token subject: [%s], user: [%s], type: [%s], issuer: [%s]", claims.Subject, claims.Text, claims.Type,
+		claims.Issuer)
+
+	err = claims.validate(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if p.Config.OrgFromToken {
+		if orgName := claims.orgName(); orgName != "" {
+			log.Debugf("using organization of the token: [%s]", orgName)
+			p.tokenOrgName = orgName
+		} else {
+			log.Warnf("no organization found in the token of [%s], using the repository owner", claims.user())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestToken assembles an unsigned JWT with the given claims
+func newTestToken(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestPlugin_CheckToken(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		token        string
+		orgFromToken bool
+		orgName      string
+		err          string
+	}{
+		{
+			name:  "valid token",
+			token: newTestToken(map[string]interface{}{"sub": "1", "exp": now.Add(time.Hour).Unix(), "nbf": now.Unix()}),
+		},
+		{
+			name: "expired token",
+			token: newTestToken(map[string]interface{}{"sub": "1", "text": "banzaicloud/pipeline",
+				"exp": time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}),
+			err: "the token of [banzaicloud/pipeline] expired at [2018-01-01T00:00:00Z]",
+		},
+		{
+			name:  "token not yet valid",
+			token: newTestToken(map[string]interface{}{"sub": "1", "nbf": time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}),
+			err:   "the token of [1] is not valid before [2100-01-01T00:00:00Z]",
+		},
+		{
+			name:  "not a JWT",
+			token: "opaque-token",
+		},
+		{
+			name:         "organization of the token",
+			token:        newTestToken(map[string]interface{}{"sub": "1", "text": "banzaicloud/pipeline", "type": "ci"}),
+			orgFromToken: true,
+			orgName:      "banzaicloud",
+		},
+		{
+			name:    "organization of the token not used",
+			token:   newTestToken(map[string]interface{}{"sub": "1", "text": "banzaicloud/pipeline", "type": "ci"}),
+			orgName: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Plugin{
+				Config: Config{
+					Token:        test.token,
+					OrgFromToken: test.orgFromToken,
+				},
+			}
+
+			err := p.checkToken()
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.orgName, p.tokenOrgName)
+		})
+	}
+}
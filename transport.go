@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// TransportSettings the settings of the HTTP client used to reach the Pipeline API
+type TransportSettings struct {
+	// CACert PEM encoded certificates of the certificate authorities trusted in addition to the system ones
+	CACert string
+	// CACertFile the file holding the CA certificates, used if CACert is not set
+	CACertFile string
+
+	// ClientCert and ClientKey the PEM encoded certificate and key the client authenticates with (mTLS)
+	ClientCert string
+	ClientKey  string
+	// ClientCertFile and ClientKeyFile the files holding the client certificate and key, used if not set inline
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// InsecureSkipVerify disables the verification of the server certificate, for development only
+	InsecureSkipVerify bool
+
+	// Proxy the URL of the HTTP(S) proxy, the proxy environment variables are used if not set
+	Proxy string
+
+	// Timeout the time limit of a single request, zero means no limit
+	Timeout time.Duration
+}
+
+// newHttpClient assembles the HTTP client according to the settings
+func newHttpClient(settings TransportSettings) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
+
+	if settings.InsecureSkipVerify {
+		log.Warn("the certificate of the Pipeline API is not verified")
+	}
+
+	caCert, err := pemOf(settings.CACert, settings.CACertFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read CA certificates")
+	}
+	if caCert != nil {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			log.Debugf("system certificates not available: [%s]", err.Error())
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("no valid CA certificate found")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	clientCert, err := pemOf(settings.ClientCert, settings.ClientCertFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read client certificate")
+	}
+	clientKey, err := pemOf(settings.ClientKey, settings.ClientKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read client key")
+	}
+	if clientCert != nil || clientKey != nil {
+		certificate, err := tls.X509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if settings.Proxy != "" {
+		proxyUrl, err := url.Parse(settings.Proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid proxy: [%s]", settings.Proxy)
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+
+	// the same as the default transport apart from the TLS and proxy settings
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   settings.Timeout,
+	}, nil
+}
+
+// pemOf returns the inline PEM if set, otherwise the content of the file; nil is returned if neither is set
+func pemOf(inline string, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(file)
+}
+
+// httpClient returns the HTTP client of the configuration, the default client if none is set
+func (config *Config) httpClient() *http.Client {
+	if config.HttpClient != nil {
+		return config.HttpClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHttpClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	dir, err := ioutil.TempDir("", "transport")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	caCertFile := filepath.Join(dir, "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(caCertFile, []byte(serverCert), 0600))
+
+	tests := []struct {
+		name        string
+		settings    TransportSettings
+		settingsErr bool
+		requestErr  bool
+	}{
+		{
+			name:       "unknown certificate authority",
+			settings:   TransportSettings{},
+			requestErr: true,
+		},
+		{
+			name:     "inline CA certificate",
+			settings: TransportSettings{CACert: serverCert},
+		},
+		{
+			name:     "CA certificate file",
+			settings: TransportSettings{CACertFile: caCertFile},
+		},
+		{
+			name:     "insecure skip verify",
+			settings: TransportSettings{InsecureSkipVerify: true},
+		},
+		{
+			name:        "invalid CA certificate",
+			settings:    TransportSettings{CACert: "invalid"},
+			settingsErr: true,
+		},
+		{
+			name:        "missing CA certificate file",
+			settings:    TransportSettings{CACertFile: filepath.Join(dir, "missing.pem")},
+			settingsErr: true,
+		},
+		{
+			name:        "client certificate without key",
+			settings:    TransportSettings{ClientCert: serverCert},
+			settingsErr: true,
+		},
+		{
+			name:        "invalid proxy",
+			settings:    TransportSettings{Proxy: "://proxy"},
+			settingsErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			httpClient, err := newHttpClient(test.settings)
+			if test.settingsErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			resp, err := httpClient.Get(server.URL)
+			if test.requestErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			resp.Body.Close()
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/imdario/mergo"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// buildValues assembles the values of a deployment: the values files are merged in order (e.g. the base values and an
+// environment overlay), then the inline values are merged over them. The secret placeholders are filled in after the
+// values are merged, in strict mode every placeholder has to have a secret.
+func buildValues(valuesFiles []string, inline map[string]interface{}, workspace string, env map[string]string, strict bool) (map[string]interface{}, error) {
+	if len(valuesFiles) == 0 && inline == nil {
+		return nil, nil
+	}
+
+	values := map[string]interface{}{}
+	for _, valuesFile := range valuesFiles {
+		fileValues, err := readValuesFile(valuesFile, workspace)
+		if err != nil {
+			return nil, err
+		}
+
+		err = mergeValues(values, fileValues)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not merge values file: [%s]", valuesFile)
+		}
+		log.Debugf("values file merged: [%s]", valuesFile)
+	}
+
+	err := mergeValues(values, inline)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not merge inline values")
+	}
+
+	return templateValues(values, env, strict)
+}
+
+// parseValues parses the inline deployment values, either a JSON or a YAML document
+func parseValues(valuesStr string) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	err := parseDocument(valuesStr, &values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseDocument parses the JSON or YAML document into v; a document encoded as a JSON string is parsed as well. The
+// errors of malformed JSON documents report the line and column of the error, the YAML parser reports the line only.
+func parseDocument(document string, v interface{}) error {
+	trimmed := strings.TrimSpace(document)
+	switch {
+	case strings.HasPrefix(trimmed, `"`):
+		var inner string
+		if err := parseJSON(document, &inner); err != nil {
+			return err
+		}
+		return parseDocument(inner, v)
+	case strings.HasPrefix(trimmed, "{"), strings.HasPrefix(trimmed, "["):
+		return parseJSON(document, v)
+	}
+
+	var parsed interface{}
+	err := yaml.Unmarshal([]byte(document), &parsed)
+	if err != nil {
+		// the errors of the YAML parser report the line of the error, but not the column
+		return errors.Wrap(err, "invalid YAML")
+	}
+
+	// the document is decoded through JSON, so that the JSON field names of v apply
+	jsonBytes, err := json.Marshal(normalizeValues(parsed))
+	if err != nil {
+		return errors.Wrap(err, "invalid YAML")
+	}
+	err = json.Unmarshal(jsonBytes, v)
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		// the offset of the re-encoded document doesn't point into the YAML document
+		return errors.Errorf("invalid YAML: unexpected %s", typeErr.Value)
+	}
+	if err != nil {
+		return errors.Wrap(err, "invalid YAML")
+	}
+	return nil
+}
+
+// parseJSON parses the JSON document into v, the errors report the line and column of the error
+func parseJSON(document string, v interface{}) error {
+	err := json.Unmarshal([]byte(document), v)
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case *json.SyntaxError:
+		line, column := position(document, err.Offset)
+		return errors.Errorf("invalid JSON at line %d, column %d: %s", line, column, err.Error())
+	case *json.UnmarshalTypeError:
+		line, column := position(document, err.Offset)
+		return errors.Errorf("invalid JSON at line %d, column %d: unexpected %s", line, column, err.Value)
+	default:
+		return errors.Wrap(err, "invalid JSON")
+	}
+}
+
+// position returns the line and column of the byte offset in the document, both counted from 1
+func position(document string, offset int64) (int, int) {
+	if offset > int64(len(document)) {
+		offset = int64(len(document))
+	}
+	if offset < 1 {
+		offset = 1
+	}
+
+	// the offset points right after the offending byte
+	preceding := document[:offset-1]
+	line := strings.Count(preceding, "\n") + 1
+	column := len(preceding) - strings.LastIndex(preceding, "\n")
+	return line, column
+}
+
+// readValuesFile reads a YAML or JSON values file, the file is resolved relative to the workspace
+func readValuesFile(valuesFile string, workspace string) (map[string]interface{}, error) {
+	valuesFile = workspacePath(valuesFile, workspace)
+
+	valuesBytes, err := ioutil.ReadFile(valuesFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read values file: [%s]", valuesFile)
+	}
+
+	var values map[string]interface{}
+	err = yaml.Unmarshal(valuesBytes, &values)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse values file: [%s]", valuesFile)
+	}
+
+	return normalizeValues(values).(map[string]interface{}), nil
+}
+
+// normalizeValues converts the maps decoded from YAML to maps keyed by strings, so that the values can be sent as JSON
+func normalizeValues(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			normalized[fmt.Sprint(key)] = normalizeValues(item)
+		}
+		return normalized
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			normalized[key] = normalizeValues(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(value))
+		for i, item := range value {
+			normalized[i] = normalizeValues(item)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
+
+// mergeValues deep-merges the values of src into dst, the values of src take precedence and lists are replaced rather
+// than appended. mergo doesn't descend into the maps held by interface values, so those are merged recursively first.
+func mergeValues(dst map[string]interface{}, src map[string]interface{}) error {
+	for key, srcValue := range src {
+		srcMap, ok := srcValue.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if dstMap, ok := dst[key].(map[string]interface{}); ok {
+			if err := mergeValues(dstMap, srcMap); err != nil {
+				return err
+			}
+		} else {
+			dst[key] = srcMap
+		}
+	}
+
+	return mergo.Merge(&dst, src, mergo.WithOverride)
+}
+
+// templateValues fills the secret placeholders of the string values
+func templateValues(values map[string]interface{}, env map[string]string, strict bool) (map[string]interface{}, error) {
+	templated, err := templateValue(values, env, strict, "")
+	if err != nil {
+		return nil, err
+	}
+	return templated.(map[string]interface{}), nil
+}
+
+// templateValue fills the secret placeholders of the value, the path of the value is reported in the errors
+func templateValue(value interface{}, env map[string]string, strict bool, valuePath string) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		templated := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			templatedItem, err := templateValue(item, env, strict, strings.TrimPrefix(valuePath+"."+key, "."))
+			if err != nil {
+				return nil, err
+			}
+			templated[key] = templatedItem
+		}
+		return templated, nil
+	case []interface{}:
+		templated := make([]interface{}, len(value))
+		for i, item := range value {
+			templatedItem, err := templateValue(item, env, strict, fmt.Sprintf("%s[%d]", valuePath, i))
+			if err != nil {
+				return nil, err
+			}
+			templated[i] = templatedItem
+		}
+		return templated, nil
+	case string:
+		if !strings.Contains(value, "{{") {
+			return value, nil
+		}
+		templated, err := processDeploymentSecrets(value, env, strict)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value of [%s]", valuePath)
+		}
+		return templated, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeValues(t *testing.T) {
+	dst := map[string]interface{}{
+		"image":     map[string]interface{}{"repository": "nginx", "tag": "1.13"},
+		"ports":     []interface{}{80, 443},
+		"replicas":  1,
+		"ingress":   false,
+		"resources": map[string]interface{}{"cpu": "100m"},
+	}
+	src := map[string]interface{}{
+		"image":     map[string]interface{}{"tag": "1.15", "pullPolicy": "Always"},
+		"ports":     []interface{}{8080},
+		"ingress":   map[string]interface{}{"enabled": true},
+		"resources": "none",
+		"service":   map[string]interface{}{"type": "LoadBalancer"},
+	}
+
+	assert.Nil(t, mergeValues(dst, src))
+	assert.Equal(t, map[string]interface{}{
+		"image":     map[string]interface{}{"repository": "nginx", "tag": "1.15", "pullPolicy": "Always"},
+		"ports":     []interface{}{8080},
+		"replicas":  1,
+		"ingress":   map[string]interface{}{"enabled": true},
+		"resources": "none",
+		"service":   map[string]interface{}{"type": "LoadBalancer"},
+	}, dst)
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		str    string
+		values map[string]interface{}
+		err    string
+	}{
+		{
+			name: "JSON escapes preserved",
+			str:  `{"pattern": "^v\\d+$", "path": "C:\\charts\\app", "cert": "-----BEGIN CERTIFICATE-----\nMIIB\n", "quote": "\"a\""}`,
+			values: map[string]interface{}{
+				"pattern": `^v\d+$`,
+				"path":    `C:\charts\app`,
+				"cert":    "-----BEGIN CERTIFICATE-----\nMIIB\n",
+				"quote":   `"a"`,
+			},
+		},
+		{
+			name:   "JSON encoded as a string",
+			str:    `"{\"image\": {\"tag\": \"1.15\"}}"`,
+			values: map[string]interface{}{"image": map[string]interface{}{"tag": "1.15"}},
+		},
+		{
+			name: "YAML",
+			str:  "replicaCount: 2\nimage:\n  tag: \"1.15\"\npattern: '^v\\d+$'\n",
+			values: map[string]interface{}{
+				"replicaCount": float64(2),
+				"image":        map[string]interface{}{"tag": "1.15"},
+				"pattern":      `^v\d+$`,
+			},
+		},
+		{
+			name: "JSON syntax error",
+			str:  "{\n  \"image\": {\n    \"tag\": 1.15,\n  }\n}",
+			err:  "invalid JSON at line 4, column 3: invalid character '}' looking for beginning of object key string",
+		},
+		{
+			name: "JSON list",
+			str:  `[1, 2]`,
+			err:  "invalid JSON at line 1, column 1: unexpected array",
+		},
+		{
+			name: "YAML syntax error",
+			str:  "image:\n  tag: [1.15\n",
+			err:  "invalid YAML: yaml: line 2: did not find expected ',' or ']'",
+		},
+		{
+			name: "YAML indented by tab",
+			str:  "image:\n  repository: nginx\n\ttag: 1.15\n",
+			err:  "invalid YAML: yaml: line 3: found a tab character that violates indentation",
+		},
+		{
+			name: "YAML list",
+			str:  "- image\n- tag\n",
+			err:  "invalid YAML: unexpected array",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := parseValues(test.str)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.values, values)
+		})
+	}
+}
+
+func TestBuildValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "values")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "values.yaml"), []byte(`
+replicaCount: 1
+image:
+  repository: wordpress
+  tag: "4.9"
+mariadb:
+  enabled: true
+  db:
+    user: wordpress
+    password: "{{ .PLUGIN_DB_PASSWORD }}"
+`), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "production.json"), []byte(`{"replicaCount": 3, "mariadb": {"db": {"user": "prod"}}}`), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "invalid.yaml"), []byte(`replicaCount: [`), 0600))
+
+	env := map[string]string{"PLUGIN_DB_PASSWORD": "secret"}
+
+	tests := []struct {
+		name        string
+		valuesFiles []string
+		inline      map[string]interface{}
+		values      map[string]interface{}
+		err         bool
+	}{
+		{
+			name: "no values",
+		},
+		{
+			name:   "inline values only",
+			inline: map[string]interface{}{"password": "{{ .PLUGIN_DB_PASSWORD }}"},
+			values: map[string]interface{}{"password": "secret"},
+		},
+		{
+			name:        "values files and inline values",
+			valuesFiles: []string{"values.yaml", filepath.Join(dir, "production.json")},
+			inline:      map[string]interface{}{"image": map[string]interface{}{"tag": "4.9.8"}},
+			values: map[string]interface{}{
+				"replicaCount": 3,
+				"image":        map[string]interface{}{"repository": "wordpress", "tag": "4.9.8"},
+				"mariadb": map[string]interface{}{
+					"enabled": true,
+					"db":      map[string]interface{}{"user": "prod", "password": "secret"},
+				},
+			},
+		},
+		{
+			name:        "missing values file",
+			valuesFiles: []string{"missing.yaml"},
+			err:         true,
+		},
+		{
+			name:        "invalid values file",
+			valuesFiles: []string{"invalid.yaml"},
+			err:         true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := buildValues(test.valuesFiles, test.inline, dir, env, false)
+			if test.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.values, values)
+		})
+	}
+}
+
+func TestTemplateValues(t *testing.T) {
+	env := map[string]string{"PLUGIN_DB_PASSWORD": `p&ss<w'o"rd>`}
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		strict bool
+		result map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "secret not escaped",
+			values: map[string]interface{}{"db": map[string]interface{}{"password": "{{ .PLUGIN_DB_PASSWORD }}"}},
+			result: map[string]interface{}{"db": map[string]interface{}{"password": `p&ss<w'o"rd>`}},
+		},
+		{
+			name: "helpers",
+			values: map[string]interface{}{
+				"json":  "{{ .PLUGIN_DB_PASSWORD | toJson }}",
+				"quote": "{{ .PLUGIN_DB_PASSWORD | quote }}",
+			},
+			result: map[string]interface{}{
+				"json":  `"p\u0026ss\u003cw'o\"rd\u003e"`,
+				"quote": `"p&ss<w'o\"rd>"`,
+			},
+		},
+		{
+			name:   "missing secret",
+			values: map[string]interface{}{"users": []interface{}{"{{ .PLUGIN_DB_USER }}"}},
+			result: map[string]interface{}{"users": []interface{}{"<no value>"}},
+		},
+		{
+			name:   "missing secret in strict mode",
+			values: map[string]interface{}{"users": []interface{}{"{{ .PLUGIN_DB_USER }}"}},
+			strict: true,
+			err:    `invalid value of [users[0]]: failed to execute template: template: depValTpl:1:3: executing "depValTpl" at <.PLUGIN_DB_USER>: map has no entry for key "PLUGIN_DB_USER"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := templateValues(test.values, env, test.strict)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.result, result)
+		})
+	}
+}
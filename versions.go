@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// latestVersion the version selector resolved to the greatest available version
+const latestVersion = "latest"
+
+// partialVersionRegexp matches versions missing the minor and / or patch parts (e.g. 1.10)
+var partialVersionRegexp = regexp.MustCompile(`^v?\d+(\.\d+)?$`)
+
+// isExactVersion checks whether the selector is a complete version rather than a version range
+func isExactVersion(selector string) bool {
+	if _, err := semver.NewVersion(selector); err != nil {
+		return false
+	}
+	core := strings.SplitN(strings.SplitN(selector, "+", 2)[0], "-", 2)[0]
+	return strings.Count(core, ".") == 2
+}
+
+// resolveVersion resolves the version selector against the available versions. The selector is either "latest", a
+// partial version (e.g. "1.10"), a semantic version constraint (e.g. "~1.10", ">=1.9 <1.11") or an exact version.
+// Exact versions are returned as they are. Pre-release and build suffixes of the available versions (e.g. the "-gke.1"
+// of GKE versions) are ignored when matching, the greatest matching version is returned.
+func resolveVersion(selector string, versions []string) (string, error) {
+	if isExactVersion(selector) {
+		return selector, nil
+	}
+
+	constraintStr := selector
+	switch {
+	case selector == latestVersion:
+		constraintStr = "*"
+	case partialVersionRegexp.MatchString(selector):
+		constraintStr = selector + ".x"
+	}
+
+	constraint, err := semver.NewConstraint(constraintStr)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid version selector: [%s]", selector)
+	}
+
+	var resolved *semver.Version
+	for _, versionStr := range versions {
+		version, err := semver.NewVersion(versionStr)
+		if err != nil {
+			// not a semantic version, it can only be selected explicitly
+			continue
+		}
+
+		release, err := version.SetPrerelease("")
+		if err != nil {
+			continue
+		}
+		release, err = release.SetMetadata("")
+		if err != nil {
+			continue
+		}
+
+		if constraint.Check(&release) && (resolved == nil || version.GreaterThan(resolved)) {
+			resolved = version
+		}
+	}
+
+	if resolved == nil {
+		return "", errors.Errorf("no version matches [%s], available versions: %s", selector, listOf(versions))
+	}
+
+	return resolved.Original(), nil
+}
+
+// resolveKubernetesVersions resolves the kubernetes version selectors of the cluster against the versions available
+// in the location of the cluster according to the Pipeline cloud info. The resolved versions are exported to the
+// workspace for later steps. Versions not set default to the latest version when the cluster is created, on update
+// they are left unset so that the cluster isn't upgraded unless asked for.
+func (p *Plugin) resolveKubernetesVersions(ctx context.Context, creating bool) error {
+	provider, err := getClusterProvider(p.Config.Cluster.Cloud)
+	if err != nil {
+		return err
+	}
+
+	versionFields := provider.kubernetesVersions(p.Config.Cluster.CreateClusterRequest)
+	if len(versionFields) == 0 {
+		return nil
+	}
+
+	resolvedVersions := map[string]string{}
+	var (
+		availableVersions []string
+		cloudInfoErr      error
+		cloudInfoFetched  bool
+	)
+
+	for name, version := range versionFields {
+		if *version == "" && creating {
+			*version = latestVersion
+		}
+
+		selector := *version
+		if selector == "" {
+			continue
+		}
+
+		if isExactVersion(selector) {
+			resolvedVersions[name] = selector
+			continue
+		}
+
+		// the available versions are only retrieved if there is anything to resolve
+		if !cloudInfoFetched {
+			cloudInfo, err := p.getCloudInfo(ctx, p.newCloudInfoRequest(constants.KeyWordKubernetesVersion))
+			if err != nil {
+				cloudInfoErr = err
+			} else {
+				availableVersions = kubernetesVersionsOf(cloudInfo)
+			}
+			cloudInfoFetched = true
+		}
+
+		if cloudInfoErr != nil {
+			if selector == latestVersion {
+				// leave it to the cloud to pick its default version
+				log.Warnf("could not resolve [%s] kubernetes version, using the default version of the cloud: [%s]",
+					selector, cloudInfoErr.Error())
+				*version = ""
+				continue
+			}
+			return errors.Wrapf(cloudInfoErr, "could not resolve kubernetes version [%s]", selector)
+		}
+
+		resolved, err := resolveVersion(selector, availableVersions)
+		if err != nil {
+			return errors.Wrap(err, "could not resolve kubernetes version")
+		}
+
+		log.Infof("kubernetes version [%s] resolved to [%s]", selector, resolved)
+		*version = resolved
+		resolvedVersions[name] = resolved
+	}
+
+	return p.exportVariables(resolvedVersions)
+}
+
+// resolveChartVersion resolves the chart version constraint of the deployment (e.g. "~0.8") against the versions of the
+// chart available in Pipeline. Exact versions are used as they are, the latest version is installed if no version is set.
+func (p *Plugin) resolveChartVersion(ctx context.Context, d *Deployment) error {
+	if d.Version == "" || isExactVersion(d.Version) {
+		return nil
+	}
+
+	var repo, chart string
+	if i := strings.Index(d.Name, "/"); i >= 0 {
+		repo, chart = d.Name[:i], d.Name[i+1:]
+	} else {
+		chart = d.Name
+	}
+
+	versions, err := p.pipelineClient().ChartVersions(ctx, p.Config.OrgId, repo, chart)
+	if err != nil {
+		return errors.Wrapf(err, "could not resolve version [%s] of chart [%s]", d.Version, d.Name)
+	}
+
+	resolved, err := resolveVersion(d.Version, chartReleasesOf(versions))
+	if err != nil {
+		return errors.Wrapf(err, "could not resolve version of chart [%s]", d.Name)
+	}
+
+	log.Infof("version [%s] of chart [%s] resolved to [%s]", d.Version, d.Name, resolved)
+	d.Version = resolved
+	return nil
+}
+
+// chartReleasesOf filters the pre-release chart versions out, they are only installed if selected explicitly
+func chartReleasesOf(versions []string) []string {
+	releases := make([]string, 0, len(versions))
+	for _, versionStr := range versions {
+		if version, err := semver.NewVersion(versionStr); err == nil && version.Prerelease() != "" {
+			continue
+		}
+		releases = append(releases, versionStr)
+	}
+	return releases
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/banzaicloud/banzai-types/components/google"
+	"github.com/banzaicloud/banzai-types/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVersion(t *testing.T) {
+	versions := []string{"1.8.11", "1.9.6", "1.9.7", "1.10.3", "1.10.5", "1.11.0"}
+	gkeVersions := []string{"1.8.12-gke.1", "1.9.7-gke.3", "1.10.2-gke.3", "1.10.4-gke.2"}
+
+	tests := []struct {
+		name     string
+		selector string
+		versions []string
+		resolved string
+		err      bool
+	}{
+		{name: "latest", selector: "latest", versions: versions, resolved: "1.11.0"},
+		{name: "minor version", selector: "1.10", versions: versions, resolved: "1.10.5"},
+		{name: "tilde range", selector: "~1.9", versions: versions, resolved: "1.9.7"},
+		{name: "exact version is kept", selector: "1.9.2", versions: versions, resolved: "1.9.2"},
+		{name: "latest gke version", selector: "latest", versions: gkeVersions, resolved: "1.10.4-gke.2"},
+		{name: "gke minor version", selector: "1.9", versions: gkeVersions, resolved: "1.9.7-gke.3"},
+		{name: "exact gke version is kept", selector: "1.9.4-gke.1", versions: gkeVersions, resolved: "1.9.4-gke.1"},
+		{name: "no matching version", selector: "1.12", versions: versions, err: true},
+		{name: "invalid selector", selector: "newest", versions: versions, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := resolveVersion(test.selector, test.versions)
+			if test.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.resolved, resolved)
+		})
+	}
+}
+
+func TestPlugin_ResolveChartVersion(t *testing.T) {
+	charts := `[{"name":"stable","charts":[[
+		{"name":"mysql","version":"0.9.0-rc.1"},
+		{"name":"mysql","version":"0.8.2"},
+		{"name":"mysql","version":"0.8.1"},
+		{"name":"mysql","version":"0.7.4"}
+	]]}]`
+
+	tests := []struct {
+		name     string
+		version  string
+		resolved string
+		calls    int
+		err      string
+	}{
+		{name: "no version", version: "", resolved: ""},
+		{name: "exact version", version: "0.7.1", resolved: "0.7.1"},
+		{name: "exact pre-release version", version: "0.9.0-rc.1", resolved: "0.9.0-rc.1"},
+		{name: "tilde range", version: "~0.8", resolved: "0.8.2", calls: 1},
+		{name: "range without pre-releases", version: ">=0.8", resolved: "0.8.2", calls: 1},
+		{name: "no matching version", version: "^1.0", calls: 1,
+			err: "could not resolve version of chart [stable/mysql]: no version matches [^1.0], available versions: [0.7.4, 0.8.1, 0.8.2]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			p := Plugin{
+				Config: Config{OrgId: 1},
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					calls++
+					assert.Equal(t, "/orgs/1/helm/charts?name=%5Emysql%24&repo=stable&version=all", url)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewBufferString(charts)),
+					}, nil
+				},
+			}
+
+			d := &Deployment{Name: "stable/mysql", Version: test.version}
+			err := p.resolveChartVersion(context.Background(), d)
+			assert.Equal(t, test.calls, calls)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.resolved, d.Version)
+		})
+	}
+}
+
+func TestPlugin_ResolveKubernetesVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		creating    bool
+		nodeVersion string
+		master      *google.Master
+	}{
+		{name: "create without version", creating: true, nodeVersion: "1.10.2-gke.3", master: &google.Master{Version: "1.10.2-gke.3"}},
+		{name: "create with version", version: "~1.9", creating: true, nodeVersion: "1.9.7-gke.3", master: &google.Master{Version: "1.9.7-gke.3"}},
+		{name: "update without version", creating: false, nodeVersion: "", master: nil},
+		{name: "update with version", version: "latest", creating: false, nodeVersion: "1.10.2-gke.3", master: &google.Master{Version: "1.10.2-gke.3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workspace, err := ioutil.TempDir("", "workspace")
+			assert.Nil(t, err)
+			defer os.RemoveAll(workspace)
+
+			c := newTestContext(map[string]string{
+				"plugin.cluster.name":           "test-cluster",
+				"plugin.cluster.provider":       constants.Google,
+				"plugin.cluster.location":       "",
+				"plugin.cluster.node_pools":     "",
+				"plugin.node.instance_type":     "",
+				"plugin.google.node.count":      "3",
+				"plugin.google.service.account": "",
+				"plugin.google.project":         "test-project",
+				"plugin.google.gke.version":     test.version,
+			})
+			provider, err := getClusterProvider(constants.Google)
+			assert.Nil(t, err)
+			setDefaults(c, provider)
+			cluster, err := newCustomCluster(c, provider)
+			assert.Nil(t, err)
+
+			p := Plugin{
+				Build:  Build{Path: workspace},
+				Config: Config{Cluster: cluster},
+				ApiCall: func(ctx context.Context, config *Config, url string, method string, body io.Reader) (*http.Response, error) {
+					assert.True(t, test.creating || test.version != "", "versions must not be resolved on update unless set")
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewBufferString(googleCloudInfoResponse)),
+					}, nil
+				},
+			}
+
+			assert.Nil(t, p.resolveKubernetesVersions(context.Background(), test.creating))
+
+			updateRequest := newUpdateClusterRequest(cluster.CreateClusterRequest)
+			assert.Equal(t, test.nodeVersion, updateRequest.Google.NodeVersion)
+			assert.Equal(t, test.master, updateRequest.Google.Master)
+		})
+	}
+}